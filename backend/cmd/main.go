@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log"
+	"time"
 
 	"github.com/baoerzuikeai/Imsystem/config"
 	"github.com/baoerzuikeai/Imsystem/internal/delivery/http/handler"
@@ -40,26 +41,88 @@ func main() {
 	chatRepo := mongodb.NewChatRepository(db)
 	fileRepo := mongodb.NewFileRepository(db)
 	aiChatRepo := mongodb.NewAIChatRepository(db)
+	inviteRepo := mongodb.NewInviteRepository(db)
+	joinRequestRepo := mongodb.NewJoinRequestRepository(db)
+	contactRepo := mongodb.NewContactRepository(db)
+	blockRepo := mongodb.NewBlockRepository(db)
+	messageSearcher := mongodb.NewMessageSearcher(db)
+	scheduledRepo := mongodb.NewScheduledMessageRepository(db)
+
+	// 为旧私聊补充唯一键后再创建索引
+	duplicateChats, err := chatRepo.BackfillPairKeys(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if duplicateChats > 0 {
+		log.Printf("marked %d duplicate private chats without pairKey", duplicateChats)
+	}
+	if err := chatRepo.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := messageRepo.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := inviteRepo.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := joinRequestRepo.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := contactRepo.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	// 旧版本的私聊好友列表来自私聊记录，迁移为联系人
+	backfilledContacts, err := contactRepo.BackfillFromPrivateChats(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if backfilledContacts > 0 {
+		log.Printf("created contacts for %d existing private chats", backfilledContacts)
+	}
+	if err := blockRepo.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := messageSearcher.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := scheduledRepo.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
 
 	// 初始化 DeepSeekClient
 	deepSeekClient := service.NewDeepSeekClient(cfg.AI.APIKey, cfg.AI.Url)
 
 	// 初始化services
-	authService := service.NewAuthService(userRepo, cfg.JWT.Secret, cfg.JWT.ExpiresIn)
-	messageService := service.NewMessageService(messageRepo, chatRepo)
-	chatService := service.NewChatService(chatRepo)
+	membershipService := service.NewMembershipService(chatRepo)
+	contactService := service.NewContactService(contactRepo, blockRepo, userRepo, membershipService)
+	authService := service.NewAuthService(userRepo, cfg.JWT.Secret, cfg.JWT.ExpiresIn, contactService)
+	messageService := service.NewMessageService(messageRepo, chatRepo, userRepo, membershipService, messageSearcher, time.Duration(cfg.Message.RecallWindow)*time.Minute)
 	fileService := service.NewFileService(fileRepo, cfg.File.BasePath)
 	aiChatService := service.NewAIChatService(aiChatRepo, deepSeekClient)
+	presenceService := service.NewPresenceService(userRepo, chatRepo, blockRepo)
+	chatService := service.NewChatService(chatRepo, messageRepo, userRepo, fileRepo, inviteRepo, joinRequestRepo, messageService, membershipService, contactService)
+	scheduledService := service.NewScheduledMessageService(scheduledRepo, messageService, membershipService, contactService)
+
+	// 在线状态由 WebSocket 连接维护，启动时清除上次运行遗留的在线状态
+	if err := presenceService.ResetPresence(ctx); err != nil {
+		log.Fatal(err)
+	}
 
 	// 初始化 WebSocket manager
-	wsManager := websocket.NewManager(messageService)
+	wsManager := websocket.NewManager(messageService, presenceService, membershipService, contactService)
 	go wsManager.Start() // 启动 WebSocket 管理器
 
+	// 启动定时消息投递任务
+	scheduler := websocket.NewScheduler(wsManager, scheduledService, time.Duration(cfg.Message.ScheduleInterval)*time.Second)
+	go scheduler.Start()
+
 	// 初始化 handlers
 	authHandler := handler.NewAuthHandler(authService)
 	wsHandler := handler.NewHandler(wsManager)
-	messageHandler := handler.NewMessageHandler(messageService)
-	chatHandler := handler.NewChatHandler(chatService)
+	messageHandler := handler.NewMessageHandler(messageService, wsManager)
+	chatHandler := handler.NewChatHandler(chatService, wsManager)
+	contactHandler := handler.NewContactHandler(contactService, wsManager)
+	scheduledHandler := handler.NewScheduledMessageHandler(scheduledService)
 	fileHandler := handler.NewFileHandler(fileService)
 	aichatHandler := handler.NewAIChatHandler(aiChatService)
 
@@ -91,21 +154,67 @@ func main() {
 
 		protected.GET("/auth/user", authHandler.GetUserDetail)
 		protected.GET("/user/search", authHandler.SearchUsers)
+		protected.GET("/search/messages", messageHandler.SearchMessages)
+		protected.PUT("/user/privacy", contactHandler.UpdatePrivacy)
+		protected.GET("/contacts", contactHandler.ListContacts)
+		protected.DELETE("/contacts/:userId", contactHandler.RemoveContact)
+		protected.GET("/contacts/requests", contactHandler.ListRequests)
+		protected.POST("/contacts/requests", contactHandler.SendRequest)
+		protected.POST("/contacts/requests/:requestId/accept", contactHandler.AcceptRequest)
+		protected.POST("/contacts/requests/:requestId/decline", contactHandler.DeclineRequest)
+		protected.GET("/blocks", contactHandler.ListBlocked)
+		protected.PUT("/blocks/:userId", contactHandler.BlockUser)
+		protected.DELETE("/blocks/:userId", contactHandler.UnblockUser)
+		protected.GET("/scheduled-messages", scheduledHandler.ListScheduled)
+		protected.PATCH("/scheduled-messages/:scheduledId", scheduledHandler.UpdateScheduled)
+		protected.DELETE("/scheduled-messages/:scheduledId", scheduledHandler.CancelScheduled)
 		protected.GET("/ws", wsHandler.HandleWebSocket)
-		protected.GET("/chats/:chatId/messages", messageHandler.GetChatMessages)
-		protected.GET("/chats/:chatId/members", chatHandler.GetChatMembers)
 		protected.GET("/chats/friends", chatHandler.GetPrivateChatFriends)
 		protected.GET("/chats/private", chatHandler.GetPrivateChatByUserID)
 		protected.POST("/chats/private", chatHandler.CreatePrivateChat)
 		protected.GET("/chats/group", chatHandler.GetGroupChatByUserID)
 		protected.POST("/chats/group", chatHandler.CreateGroupChat)
 		protected.GET("/chats", chatHandler.GetAllChats)
+		protected.POST("/invites/:token/join", chatHandler.JoinByInvite)
+		protected.POST("/chats/:chatId/join", chatHandler.JoinChat)
 		protected.POST("/files/upload", fileHandler.UploadFile)
 		protected.GET("/files/:fileId", fileHandler.GetFileByID)
 		//ai聊天
 		protected.POST("/ai/chat", aichatHandler.HandleAIChat)
 	}
 
+	// 聊天内的路由，需要是聊天成员
+	chatScoped := protected.Group("/chats/:chatId")
+	chatScoped.Use(middleware.ChatMemberMiddleware(membershipService))
+	{
+		chatScoped.GET("/messages", messageHandler.GetChatMessages)
+		chatScoped.PATCH("/messages/:messageId", messageHandler.EditMessage)
+		chatScoped.DELETE("/messages/:messageId", messageHandler.DeleteMessage)
+		chatScoped.GET("/messages/:messageId/thread", messageHandler.GetThread)
+		chatScoped.PUT("/messages/:messageId/reactions/:emoji", messageHandler.AddReaction)
+		chatScoped.DELETE("/messages/:messageId/reactions/:emoji", messageHandler.RemoveReaction)
+		chatScoped.PUT("/messages/:messageId/pin", chatHandler.PinMessage)
+		chatScoped.DELETE("/messages/:messageId/pin", chatHandler.UnpinMessage)
+		chatScoped.GET("/pins", chatHandler.ListPins)
+		chatScoped.POST("/forward", chatHandler.ForwardMessages)
+		chatScoped.POST("/scheduled-messages", scheduledHandler.Schedule)
+		chatScoped.POST("/read", messageHandler.MarkRead)
+		chatScoped.GET("/members", chatHandler.GetChatMembers)
+		chatScoped.POST("/members", chatHandler.AddMembers)
+		chatScoped.DELETE("/members/:userId", chatHandler.RemoveMember)
+		chatScoped.PUT("/members/:userId/role", chatHandler.SetMemberRole)
+		chatScoped.POST("/leave", chatHandler.LeaveChat)
+		chatScoped.POST("/transfer", chatHandler.TransferOwnership)
+		chatScoped.PUT("/settings", chatHandler.UpdateSettings)
+		chatScoped.PUT("/preferences", chatHandler.UpdatePreferences)
+		chatScoped.GET("/invites", chatHandler.ListInvites)
+		chatScoped.POST("/invites", chatHandler.CreateInvite)
+		chatScoped.DELETE("/invites/:inviteId", chatHandler.RevokeInvite)
+		chatScoped.GET("/join-requests", chatHandler.ListJoinRequests)
+		chatScoped.POST("/join-requests/:requestId/approve", chatHandler.ApproveJoinRequest)
+		chatScoped.POST("/join-requests/:requestId/reject", chatHandler.RejectJoinRequest)
+	}
+
 	// 启动服务器
 	if err := r.Run("0.0.0.0:" + cfg.Server.Port); err != nil {
 		log.Fatal(err)
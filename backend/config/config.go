@@ -10,6 +10,7 @@ type Config struct {
 	JWT     JWTConfig
 	AI      AIConfig
 	File    FileConfig
+	Message MessageConfig
 }
 
 type ServerConfig struct {
@@ -37,6 +38,11 @@ type FileConfig struct {
 	BasePath string
 }
 
+type MessageConfig struct {
+	RecallWindow     int64 // 发送者可撤回消息的时间窗口（分钟）
+	ScheduleInterval int64 // 扫描到期定时消息的间隔（秒）
+}
+
 func LoadConfig(path string) (*Config, error) {
 	viper.SetConfigFile(path)
 	viper.AutomaticEnv()
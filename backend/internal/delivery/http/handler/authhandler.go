@@ -98,7 +98,7 @@ func (h *AuthHandler) SearchUsers(c *gin.Context) {
         return
     }
 
-    users, err := h.authService.SearchUsers(c.Request.Context(), keyword)
+    users, err := h.authService.SearchUsers(c.Request.Context(), keyword, c.GetString("userID"))
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
@@ -1,19 +1,27 @@
 package handler
 
 import (
+	"encoding/json"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
 	"github.com/baoerzuikeai/Imsystem/internal/service"
+	ws "github.com/baoerzuikeai/Imsystem/internal/websocket"
 	"github.com/gin-gonic/gin"
 )
 
 type ChatHandler struct {
 	chatService service.ChatService
+	manager     *ws.Manager
 }
 
-func NewChatHandler(chatService service.ChatService) *ChatHandler {
+func NewChatHandler(chatService service.ChatService, manager *ws.Manager) *ChatHandler {
 	return &ChatHandler{
 		chatService: chatService,
+		manager:     manager,
 	}
 }
 
@@ -98,13 +106,18 @@ func (h *ChatHandler) CreatePrivateChat(c *gin.Context) {
 	}
 
 	// 创建 Private Chat
-	chat, err := h.chatService.CreatePrivateChat(c.Request.Context(), strUserID, request.TargetUserID)
+	// 已有私聊时返回 200，新建时返回 201
+	chat, created, err := h.chatService.CreatePrivateChat(c.Request.Context(), strUserID, request.TargetUserID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
+		return
+	}
+	if !created {
+		c.JSON(http.StatusOK, gin.H{"chat": chat, "message": "私聊已存在"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"chat": chat, "message": "创建成功"})
+	c.JSON(http.StatusCreated, gin.H{"chat": chat, "message": "创建成功"})
 }
 
 func (h *ChatHandler) GetAllChats(c *gin.Context) {
@@ -119,13 +132,19 @@ func (h *ChatHandler) GetAllChats(c *gin.Context) {
 		return
 	}
 
-	chats, err := h.chatService.GetAllChatsByUserID(c.Request.Context(), strUserID)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	archived, _ := strconv.ParseBool(c.Query("archived"))
+	page, err := h.chatService.ListChats(c.Request.Context(), strUserID, service.ChatListOptions{
+		Cursor:   c.Query("cursor"),
+		Limit:    limit,
+		Archived: archived,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, chats)
+	c.JSON(http.StatusOK, page)
 }
 
 func (h *ChatHandler) GetChatMembers(c *gin.Context) {
@@ -181,3 +200,362 @@ func (h *ChatHandler) CreateGroupChat(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"chat": chat, "message": "创建成功"})
 }
+
+// AddMembers 群主或管理员添加群成员
+func (h *ChatHandler) AddMembers(c *gin.Context) {
+	var request struct {
+		UserIDs []string `json:"userIds" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	change, err := h.chatService.AddMembers(c.Request.Context(), c.Param("chatId"), c.GetString("userID"), request.UserIDs)
+	h.respondMemberChange(c, change, err)
+}
+
+// RemoveMember 群主或管理员移除群成员
+func (h *ChatHandler) RemoveMember(c *gin.Context) {
+	change, err := h.chatService.RemoveMember(c.Request.Context(), c.Param("chatId"), c.GetString("userID"), c.Param("userId"))
+	h.respondMemberChange(c, change, err)
+}
+
+// LeaveChat 当前用户退出群聊
+func (h *ChatHandler) LeaveChat(c *gin.Context) {
+	change, err := h.chatService.LeaveChat(c.Request.Context(), c.Param("chatId"), c.GetString("userID"))
+	h.respondMemberChange(c, change, err)
+}
+
+// SetMemberRole 群主设置或取消管理员
+func (h *ChatHandler) SetMemberRole(c *gin.Context) {
+	var request struct {
+		Role string `json:"role" binding:"required"` // 'admin' 或 'member'
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	change, err := h.chatService.SetMemberRole(c.Request.Context(), c.Param("chatId"), c.GetString("userID"), c.Param("userId"), request.Role)
+	h.respondMemberChange(c, change, err)
+}
+
+// TransferOwnership 群主转让群主身份
+func (h *ChatHandler) TransferOwnership(c *gin.Context) {
+	var request struct {
+		UserID string `json:"userId" binding:"required"` // 新群主 ID
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	change, err := h.chatService.TransferOwnership(c.Request.Context(), c.Param("chatId"), c.GetString("userID"), request.UserID)
+	h.respondMemberChange(c, change, err)
+}
+
+// respondMemberChange 推送系统消息和成员变化事件，并返回结果
+func (h *ChatHandler) respondMemberChange(c *gin.Context, change *service.MemberChange, err error) {
+	if change != nil {
+		// 出错前已生效的变化也要推送
+		h.broadcastMemberChange(change)
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if change == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "没有变化"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"event": change.Event, "message": "操作成功"})
+}
+
+// broadcastMemberChange 推送系统消息和成员变化事件
+func (h *ChatHandler) broadcastMemberChange(change *service.MemberChange) {
+	event := change.Event
+	if change.SystemMessage != nil {
+		if messageJSON, err := json.Marshal(change.SystemMessage); err == nil {
+			h.manager.Broadcast(event.ChatID, messageJSON)
+		} else {
+			log.Printf("Error marshaling system message: %v", err)
+		}
+	}
+	h.manager.BroadcastEvent(event.ChatID, ws.WSEventMemberChanged, event)
+
+	// 被移除或退出的用户已不在成员列表中，单独通知
+	if event.Action == domain.MemberRemoved || event.Action == domain.MemberLeft {
+		for _, userID := range event.UserIDs {
+			h.manager.SendEventToUser(userID, ws.WSEventMemberChanged, event)
+		}
+	}
+}
+
+// UpdateSettings 修改群设置，并推送 chat_updated 事件
+func (h *ChatHandler) UpdateSettings(c *gin.Context) {
+	var request struct {
+		Title        *string `json:"title"`
+		AvatarFileID *string `json:"avatarFileId"` // 已上传的图片文件 ID
+		Description  *string `json:"description"`
+		Announcement *string `json:"announcement"` // 空字符串表示清除公告
+		JoinApproval *bool   `json:"joinApproval"`
+		Public       *bool   `json:"public"` // 是否允许不通过邀请链接直接加入
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	chatID := c.Param("chatId")
+	change, err := h.chatService.UpdateSettings(c.Request.Context(), chatID, c.GetString("userID"), service.ChatSettingsRequest{
+		Title:        request.Title,
+		AvatarFileID: request.AvatarFileID,
+		Description:  request.Description,
+		Announcement: request.Announcement,
+		JoinApproval: request.JoinApproval,
+		Public:       request.Public,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	for _, message := range change.SystemMessages {
+		if messageJSON, err := json.Marshal(message); err == nil {
+			h.manager.Broadcast(chatID, messageJSON)
+		}
+	}
+	h.manager.BroadcastEvent(chatID, ws.WSEventChatUpdated, change.Chat)
+
+	c.JSON(http.StatusOK, gin.H{"chat": change.Chat, "message": "更新成功"})
+}
+
+// CreateInvite 创建群聊邀请链接
+func (h *ChatHandler) CreateInvite(c *gin.Context) {
+	var request struct {
+		ExpiresIn        int64 `json:"expiresIn"` // 有效期（秒），0 表示永不过期
+		MaxUses          int   `json:"maxUses"`   // 最大使用次数，0 表示不限
+		RequiresApproval bool  `json:"requiresApproval"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	invite, err := h.chatService.CreateInvite(c.Request.Context(), c.Param("chatId"), c.GetString("userID"), service.InviteOptions{
+		ExpiresIn:        time.Duration(request.ExpiresIn) * time.Second,
+		MaxUses:          request.MaxUses,
+		RequiresApproval: request.RequiresApproval,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"invite": invite})
+}
+
+// ListInvites 获取群聊中仍有效的邀请链接
+func (h *ChatHandler) ListInvites(c *gin.Context) {
+	invites, err := h.chatService.ListInvites(c.Request.Context(), c.Param("chatId"), c.GetString("userID"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invites": invites})
+}
+
+// RevokeInvite 撤销邀请链接
+func (h *ChatHandler) RevokeInvite(c *gin.Context) {
+	err := h.chatService.RevokeInvite(c.Request.Context(), c.Param("chatId"), c.GetString("userID"), c.Param("inviteId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "邀请链接已撤销"})
+}
+
+// JoinByInvite 通过邀请链接加入群聊
+func (h *ChatHandler) JoinByInvite(c *gin.Context) {
+	result, err := h.chatService.JoinByInvite(c.Request.Context(), c.Param("token"), c.GetString("userID"))
+	h.respondJoin(c, result, err)
+}
+
+// JoinChat 申请加入群聊
+func (h *ChatHandler) JoinChat(c *gin.Context) {
+	var request struct {
+		Message string `json:"message"` // 申请留言，群聊需要审批时展示给管理员
+	}
+	// 请求体可以为空
+	_ = c.ShouldBindJSON(&request)
+
+	result, err := h.chatService.JoinChat(c.Request.Context(), c.Param("chatId"), c.GetString("userID"), request.Message)
+	h.respondJoin(c, result, err)
+}
+
+// respondJoin 直接加入时推送成员变化，需要审批时通知群主和管理员
+func (h *ChatHandler) respondJoin(c *gin.Context, result *service.JoinResult, err error) {
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	switch {
+	case result.Request != nil:
+		if result.RequestCreated {
+			for _, adminID := range result.AdminIDs {
+				h.manager.SendEventToUser(adminID, ws.WSEventJoinRequest, result.Request)
+			}
+		}
+		c.JSON(http.StatusAccepted, gin.H{"request": result.Request, "message": "已提交入群申请，等待管理员审批"})
+	case result.Change != nil:
+		h.broadcastMemberChange(result.Change)
+		c.JSON(http.StatusOK, gin.H{"chat": result.Chat, "message": "加入成功"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"chat": result.Chat, "message": "已经是群成员"})
+	}
+}
+
+// ListJoinRequests 获取待处理的入群申请
+func (h *ChatHandler) ListJoinRequests(c *gin.Context) {
+	requests, err := h.chatService.ListJoinRequests(c.Request.Context(), c.Param("chatId"), c.GetString("userID"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+// ApproveJoinRequest 通过入群申请
+func (h *ChatHandler) ApproveJoinRequest(c *gin.Context) {
+	decision, err := h.chatService.ApproveJoinRequest(c.Request.Context(), c.Param("chatId"), c.GetString("userID"), c.Param("requestId"))
+	h.respondJoinDecision(c, decision, err)
+}
+
+// RejectJoinRequest 拒绝入群申请
+func (h *ChatHandler) RejectJoinRequest(c *gin.Context) {
+	decision, err := h.chatService.RejectJoinRequest(c.Request.Context(), c.Param("chatId"), c.GetString("userID"), c.Param("requestId"))
+	h.respondJoinDecision(c, decision, err)
+}
+
+// respondJoinDecision 通知申请人处理结果，通过时推送成员变化
+func (h *ChatHandler) respondJoinDecision(c *gin.Context, decision *service.JoinDecision, err error) {
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if decision.Change != nil {
+		h.broadcastMemberChange(decision.Change)
+	}
+	h.manager.SendEventToUser(decision.Request.UserID, ws.WSEventJoinRequestResolved, decision.Request)
+
+	c.JSON(http.StatusOK, gin.H{"request": decision.Request, "message": "操作成功"})
+}
+
+// UpdatePreferences 修改当前用户对聊天的置顶、免打扰和归档设置，并同步到该用户的其它连接
+func (h *ChatHandler) UpdatePreferences(c *gin.Context) {
+	var request struct {
+		Pinned     *bool      `json:"pinned"`
+		Muted      *bool      `json:"muted"`
+		MutedUntil *time.Time `json:"mutedUntil"` // 为空表示一直免打扰
+		Archived   *bool      `json:"archived"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	chatID := c.Param("chatId")
+	userID := c.GetString("userID")
+	member, err := h.chatService.UpdatePreferences(c.Request.Context(), chatID, userID, domain.MemberPreferencesUpdate{
+		Pinned:     request.Pinned,
+		Muted:      request.Muted,
+		MutedUntil: request.MutedUntil,
+		Archived:   request.Archived,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	h.manager.SendEventToUser(userID, ws.WSEventPreferences, gin.H{"chatId": chatID, "member": member})
+	c.JSON(http.StatusOK, gin.H{"member": member, "message": "更新成功"})
+}
+
+// PinMessage 置顶消息，广播系统消息和 pinned 事件
+func (h *ChatHandler) PinMessage(c *gin.Context) {
+	chatID := c.Param("chatId")
+	change, err := h.chatService.PinMessage(c.Request.Context(), chatID, c.GetString("userID"), c.Param("messageId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	h.respondPinChange(c, chatID, change, "置顶成功")
+}
+
+// UnpinMessage 取消置顶，广播 pinned 事件
+func (h *ChatHandler) UnpinMessage(c *gin.Context) {
+	chatID := c.Param("chatId")
+	change, err := h.chatService.UnpinMessage(c.Request.Context(), chatID, c.GetString("userID"), c.Param("messageId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	h.respondPinChange(c, chatID, change, "已取消置顶")
+}
+
+func (h *ChatHandler) respondPinChange(c *gin.Context, chatID string, change *service.PinChange, message string) {
+	if change.SystemMessage != nil {
+		if messageJSON, err := json.Marshal(change.SystemMessage); err == nil {
+			h.manager.Broadcast(chatID, messageJSON)
+		}
+	}
+	h.manager.BroadcastEvent(chatID, ws.WSEventPinned, change.Update)
+	c.JSON(http.StatusOK, gin.H{"pin": change.Update, "message": message})
+}
+
+// ListPins 获取聊天的置顶消息，按置顶时间倒序
+func (h *ChatHandler) ListPins(c *gin.Context) {
+	pins, err := h.chatService.ListPins(c.Request.Context(), c.Param("chatId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pins": pins})
+}
+
+// ForwardMessages 将当前聊天中的消息转发到其他聊天，并向每个目标聊天广播新消息
+func (h *ChatHandler) ForwardMessages(c *gin.Context) {
+	var request struct {
+		MessageIDs    []string `json:"messageIds" binding:"required"`
+		TargetChatIDs []string `json:"targetChatIds" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	messages, err := h.chatService.ForwardMessages(c.Request.Context(), c.GetString("userID"), c.Param("chatId"), request.MessageIDs, request.TargetChatIDs)
+	// 部分消息转发成功后出错时，已保存的消息仍然广播
+	for _, message := range messages {
+		if messageJSON, err := json.Marshal(message); err == nil {
+			h.manager.Broadcast(message.ChatID, messageJSON)
+			h.manager.NotifyMessage(message)
+		}
+	}
+	if err != nil && len(messages) > 0 {
+		// 返回已转发的消息，客户端据此判断哪些已经成功
+		c.JSON(errorStatus(err), gin.H{"error": err.Error(), "messages": messages})
+		return
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": messages, "message": "转发成功"})
+}
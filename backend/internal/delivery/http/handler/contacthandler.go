@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"github.com/baoerzuikeai/Imsystem/internal/service"
+	ws "github.com/baoerzuikeai/Imsystem/internal/websocket"
+	"github.com/gin-gonic/gin"
+)
+
+type ContactHandler struct {
+	contactService service.ContactService
+	manager        *ws.Manager
+}
+
+func NewContactHandler(contactService service.ContactService, manager *ws.Manager) *ContactHandler {
+	return &ContactHandler{
+		contactService: contactService,
+		manager:        manager,
+	}
+}
+
+// ListContacts 获取联系人列表
+func (h *ContactHandler) ListContacts(c *gin.Context) {
+	contacts, err := h.contactService.ListContacts(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"contacts": contacts})
+}
+
+// RemoveContact 删除联系人
+func (h *ContactHandler) RemoveContact(c *gin.Context) {
+	if err := h.contactService.RemoveContact(c.Request.Context(), c.GetString("userID"), c.Param("userId")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已删除联系人"})
+}
+
+// SendRequest 发送好友申请，对方已向自己发出申请时直接成为联系人
+func (h *ContactHandler) SendRequest(c *gin.Context) {
+	var request struct {
+		UserID  string `json:"userId" binding:"required"` // 目标用户 ID
+		Message string `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	result, err := h.contactService.SendRequest(c.Request.Context(), c.GetString("userID"), request.UserID, request.Message)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if result.Accepted {
+		h.manager.SendEventToUser(result.Request.FromUserID, ws.WSEventFriendRequestResolved, result.Request)
+		c.JSON(http.StatusOK, gin.H{"request": result.Request, "message": "已成为联系人"})
+		return
+	}
+	if result.Created {
+		h.manager.SendEventToUser(request.UserID, ws.WSEventFriendRequest, result.Request)
+	}
+	c.JSON(http.StatusCreated, gin.H{"request": result.Request, "message": "好友申请已发送"})
+}
+
+// ListRequests 获取收到和发出的待处理好友申请
+func (h *ContactHandler) ListRequests(c *gin.Context) {
+	requests, err := h.contactService.ListRequests(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// AcceptRequest 接受好友申请
+func (h *ContactHandler) AcceptRequest(c *gin.Context) {
+	request, err := h.contactService.AcceptRequest(c.Request.Context(), c.GetString("userID"), c.Param("requestId"))
+	h.respondRequestResolved(c, request, err)
+}
+
+// DeclineRequest 拒绝好友申请
+func (h *ContactHandler) DeclineRequest(c *gin.Context) {
+	request, err := h.contactService.DeclineRequest(c.Request.Context(), c.GetString("userID"), c.Param("requestId"))
+	h.respondRequestResolved(c, request, err)
+}
+
+// respondRequestResolved 通知申请人处理结果
+func (h *ContactHandler) respondRequestResolved(c *gin.Context, request *domain.FriendRequest, err error) {
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	h.manager.SendEventToUser(request.FromUserID, ws.WSEventFriendRequestResolved, request)
+	c.JSON(http.StatusOK, gin.H{"request": request, "message": "操作成功"})
+}
+
+// UpdatePrivacy 修改隐私设置
+func (h *ContactHandler) UpdatePrivacy(c *gin.Context) {
+	var privacy domain.Privacy
+	if err := c.ShouldBindJSON(&privacy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if err := h.contactService.UpdatePrivacy(c.Request.Context(), c.GetString("userID"), privacy); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"privacy": privacy, "message": "更新成功"})
+}
+
+// ListBlocked 获取已屏蔽的用户
+func (h *ContactHandler) ListBlocked(c *gin.Context) {
+	users, err := h.contactService.ListBlocked(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"users": users})
+}
+
+// BlockUser 屏蔽用户
+func (h *ContactHandler) BlockUser(c *gin.Context) {
+	if err := h.contactService.BlockUser(c.Request.Context(), c.GetString("userID"), c.Param("userId")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已屏蔽该用户"})
+}
+
+// UnblockUser 取消屏蔽
+func (h *ContactHandler) UnblockUser(c *gin.Context) {
+	if err := h.contactService.UnblockUser(c.Request.Context(), c.GetString("userID"), c.Param("userId")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已取消屏蔽"})
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/baoerzuikeai/Imsystem/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// respondError 将业务错误映射为对应的 HTTP 状态码
+func respondError(c *gin.Context, err error) {
+	c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+}
+
+// errorStatus 返回业务错误对应的 HTTP 状态码，未知错误为 500
+func errorStatus(err error) int {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrMessageNotFound), errors.Is(err, service.ErrTargetNotMember),
+		errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrFileNotFound),
+		errors.Is(err, service.ErrInviteNotFound), errors.Is(err, service.ErrJoinRequestNotFound),
+		errors.Is(err, service.ErrFriendRequestNotFound), errors.Is(err, service.ErrNotContact),
+		errors.Is(err, service.ErrNotBlocked), errors.Is(err, service.ErrMessageNotPinned),
+		errors.Is(err, service.ErrScheduledMessageNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrNotMessageSender), errors.Is(err, service.ErrPermissionDenied),
+		errors.Is(err, service.ErrRecallExpired), errors.Is(err, service.ErrNotChatMember),
+		errors.Is(err, service.ErrPrivacyRestricted), errors.Is(err, service.ErrBlocked),
+		errors.Is(err, service.ErrMentionNotAllowed):
+		status = http.StatusForbidden
+	case errors.Is(err, service.ErrInviteExpired):
+		status = http.StatusGone
+	case errors.Is(err, service.ErrMessageDeleted), errors.Is(err, service.ErrOwnerCannotLeave),
+		errors.Is(err, service.ErrJoinRequestHandled), errors.Is(err, service.ErrAlreadyContact),
+		errors.Is(err, service.ErrFriendRequestHandled), errors.Is(err, service.ErrMessageAlreadyPinned),
+		errors.Is(err, service.ErrPinLimitReached), errors.Is(err, service.ErrScheduledMessageHandled):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrMessageNotEditable), errors.Is(err, service.ErrEmptyContent),
+		errors.Is(err, service.ErrInvalidReference), errors.Is(err, service.ErrInvalidEmoji),
+		errors.Is(err, service.ErrInvalidCursor), errors.Is(err, service.ErrNotGroupChat),
+		errors.Is(err, service.ErrInvalidRole), errors.Is(err, service.ErrInvalidChatSettings),
+		errors.Is(err, service.ErrInvalidInvite), errors.Is(err, service.ErrInvalidContact),
+		errors.Is(err, service.ErrInvalidPrivacy), errors.Is(err, service.ErrInvalidBlock),
+		errors.Is(err, service.ErrInvalidPreferences), errors.Is(err, service.ErrInvalidSearch),
+		errors.Is(err, service.ErrMessageNotPinnable), errors.Is(err, service.ErrInvalidForward),
+		errors.Is(err, service.ErrInvalidSchedule):
+		status = http.StatusBadRequest
+	}
+	return status
+}
@@ -3,31 +3,221 @@ package handler
 import (
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
 	"github.com/baoerzuikeai/Imsystem/internal/service"
+	ws "github.com/baoerzuikeai/Imsystem/internal/websocket"
 	"github.com/gin-gonic/gin"
 )
 
 type MessageHandler struct {
 	messageService service.MessageService
+	manager        *ws.Manager
 }
 
-func NewMessageHandler(messageService service.MessageService) *MessageHandler {
+func NewMessageHandler(messageService service.MessageService, manager *ws.Manager) *MessageHandler {
 	return &MessageHandler{
 		messageService: messageService,
+		manager:        manager,
 	}
 }
 
+// GetChatMessages 获取历史消息。传入 before/after/around 任一参数时使用游标分页，返回最新在前的分页结果，
+// before 为空表示从最新消息开始；否则保持旧版 limit/offset 行为，返回按时间正序的消息数组
 func (h *MessageHandler) GetChatMessages(c *gin.Context) {
 	chatID := c.Param("chatId")
+
+	if !hasAnyQuery(c, "before", "after", "around") {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+		messages, err := h.messageService.GetByChatID(c.Request.Context(), chatID, limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, messages)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	page, err := h.messageService.GetPage(c.Request.Context(), chatID, service.MessageCursor{
+		Before: c.Query("before"),
+		After:  c.Query("after"),
+		Around: c.Query("around"),
+		Limit:  limit,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// EditMessage 编辑消息，并向聊天成员广播 edited 事件
+func (h *MessageHandler) EditMessage(c *gin.Context) {
+	userID := c.GetString("userID")
+	chatID := c.Param("chatId")
+	messageID := c.Param("messageId")
+
+	var request struct {
+		Content  string `json:"content" binding:"required"`
+		Language string `json:"language"` // 仅代码消息使用，为空时保留原语言
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	message, err := h.messageService.EditMessage(c.Request.Context(), chatID, messageID, userID, request.Content, request.Language)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	h.manager.BroadcastEvent(chatID, ws.WSEventEdited, message)
+	c.JSON(http.StatusOK, message)
+}
+
+// DeleteMessage 撤回/删除消息，并向聊天成员广播 message_deleted 事件，消息被置顶时同时广播取消置顶
+func (h *MessageHandler) DeleteMessage(c *gin.Context) {
+	userID := c.GetString("userID")
+	chatID := c.Param("chatId")
+	messageID := c.Param("messageId")
+
+	message, unpinned, err := h.messageService.DeleteMessage(c.Request.Context(), chatID, messageID, userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	h.manager.BroadcastEvent(chatID, ws.WSEventMessageDeleted, message)
+	if unpinned != nil {
+		h.manager.BroadcastEvent(chatID, ws.WSEventPinned, unpinned)
+	}
+	c.JSON(http.StatusOK, message)
+}
+
+// GetThread 分页获取话题回复
+func (h *MessageHandler) GetThread(c *gin.Context) {
+	chatID := c.Param("chatId")
+	messageID := c.Param("messageId")
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 
-	messages, err := h.messageService.GetByChatID(c.Request.Context(), chatID, limit, offset)
+	root, replies, err := h.messageService.GetThread(c.Request.Context(), chatID, messageID, limit, offset)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"root": root, "replies": replies})
+}
+
+// AddReaction 添加表情回应
+func (h *MessageHandler) AddReaction(c *gin.Context) {
+	h.react(c, true)
+}
+
+// RemoveReaction 移除表情回应
+func (h *MessageHandler) RemoveReaction(c *gin.Context) {
+	h.react(c, false)
+}
+
+func (h *MessageHandler) react(c *gin.Context, add bool) {
+	userID := c.GetString("userID")
+	chatID := c.Param("chatId")
+	messageID := c.Param("messageId")
+
+	update, err := h.messageService.React(c.Request.Context(), chatID, messageID, userID, c.Param("emoji"), add)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if update != nil {
+		h.manager.BroadcastEvent(chatID, ws.WSEventReaction, update)
+	}
+	c.JSON(http.StatusOK, gin.H{"changed": update != nil})
+}
+
+// MarkRead 标记已读到指定消息，并向聊天成员广播已读回执
+func (h *MessageHandler) MarkRead(c *gin.Context) {
+	userID := c.GetString("userID")
+	chatID := c.Param("chatId")
+
+	var request struct {
+		MessageID string `json:"messageId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	receipt, err := h.messageService.MarkRead(c.Request.Context(), chatID, userID, request.MessageID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if receipt != nil {
+		h.manager.BroadcastEvent(chatID, ws.WSEventRead, receipt)
+	}
+	c.JSON(http.StatusOK, gin.H{"changed": receipt != nil})
+}
+
+// SearchMessages 在当前用户所在的聊天中检索消息。
+// 查询参数：q 关键字，chatId、senderId、type（text/code）过滤，from/to 为 RFC3339 时间，cursor/limit 分页
+func (h *MessageHandler) SearchMessages(c *gin.Context) {
+	opts := service.MessageSearchOptions{
+		Keyword:  c.Query("q"),
+		ChatID:   c.Query("chatId"),
+		SenderID: c.Query("senderId"),
+		Type:     domain.MessageType(c.Query("type")),
+		Cursor:   c.Query("cursor"),
+	}
+	opts.Limit, _ = strconv.Atoi(c.Query("limit"))
+	var err error
+	if opts.From, err = parseTimeQuery(c, "from"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "时间格式错误"})
+		return
+	}
+	if opts.To, err = parseTimeQuery(c, "to"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "时间格式错误"})
+		return
+	}
+
+	page, err := h.messageService.SearchMessages(c.Request.Context(), c.GetString("userID"), opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, messages)
+	c.JSON(http.StatusOK, page)
+}
+
+// parseTimeQuery 解析 RFC3339 格式的时间查询参数，参数为空时返回 nil
+func parseTimeQuery(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// hasAnyQuery 请求中是否带有任一查询参数，参数值可以为空
+func hasAnyQuery(c *gin.Context, names ...string) bool {
+	for _, name := range names {
+		if _, ok := c.GetQuery(name); ok {
+			return true
+		}
+	}
+	return false
 }
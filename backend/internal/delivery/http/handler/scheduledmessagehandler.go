@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"github.com/baoerzuikeai/Imsystem/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduledMessageHandler struct {
+	scheduledService service.ScheduledMessageService
+}
+
+func NewScheduledMessageHandler(scheduledService service.ScheduledMessageService) *ScheduledMessageHandler {
+	return &ScheduledMessageHandler{
+		scheduledService: scheduledService,
+	}
+}
+
+// Schedule 在当前聊天中创建定时消息，到达 sendAt 时由后台任务投递
+func (h *ScheduledMessageHandler) Schedule(c *gin.Context) {
+	var request struct {
+		Type     string    `json:"type"`                       // 'text'（默认）、'code' 或 'file'
+		Content  string    `json:"content" binding:"required"` // 文件消息为文件 ID
+		Language string    `json:"language"`
+		FileName string    `json:"fileName"`
+		SendAt   time.Time `json:"sendAt" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	scheduled, err := h.scheduledService.Schedule(c.Request.Context(), c.GetString("userID"), c.Param("chatId"), service.ScheduledDraft{
+		Type:     domain.MessageType(request.Type),
+		Content:  request.Content,
+		Language: request.Language,
+		FileName: request.FileName,
+		SendAt:   request.SendAt,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"scheduledMessage": scheduled, "message": "创建成功"})
+}
+
+// ListScheduled 获取当前用户待投递的定时消息，可以用 chatId 查询参数限定聊天
+func (h *ScheduledMessageHandler) ListScheduled(c *gin.Context) {
+	scheduled, err := h.scheduledService.ListPending(c.Request.Context(), c.GetString("userID"), c.Query("chatId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"scheduledMessages": scheduled})
+}
+
+// UpdateScheduled 修改待投递的定时消息的内容或投递时间
+func (h *ScheduledMessageHandler) UpdateScheduled(c *gin.Context) {
+	var request struct {
+		Content  *string    `json:"content"`
+		Language *string    `json:"language"` // 仅代码消息使用
+		SendAt   *time.Time `json:"sendAt"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	scheduled, err := h.scheduledService.Update(c.Request.Context(), c.GetString("userID"), c.Param("scheduledId"), service.ScheduledUpdate{
+		Content:  request.Content,
+		Language: request.Language,
+		SendAt:   request.SendAt,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"scheduledMessage": scheduled, "message": "更新成功"})
+}
+
+// CancelScheduled 取消待投递的定时消息
+func (h *ScheduledMessageHandler) CancelScheduled(c *gin.Context) {
+	if err := h.scheduledService.Cancel(c.Request.Context(), c.GetString("userID"), c.Param("scheduledId")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已取消定时消息"})
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/baoerzuikeai/Imsystem/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ChatMemberMiddleware 校验当前用户是路由中 :chatId 对应聊天的成员
+func ChatMemberMiddleware(membershipService service.MembershipService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := membershipService.CheckMember(c.Request.Context(), c.Param("chatId"), c.GetString("userID"))
+		if err != nil {
+			if errors.Is(err, service.ErrNotChatMember) {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -6,6 +6,19 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// 聊天类型
+const (
+	ChatTypePrivate = "private"
+	ChatTypeGroup   = "group"
+)
+
+// 成员角色
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
 // Chat 表示聊天的领域模型
 type Chat struct {
 	ID            primitive.ObjectID `bson:"_id" json:"id"`                            // 聊天 ID
@@ -16,11 +29,128 @@ type Chat struct {
 	LastMessageAt time.Time          `bson:"lastMessageAt" json:"lastMessageAt"`       // 最后消息时间
 	CreatedBy     string             `bson:"createdBy" json:"createdBy"`               // 创建者 ID
 	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`               // 创建时间
+	LastSeq       int64              `bson:"lastSeq" json:"lastSeq"`                   // 最后分配的消息序号
+
+	Description  string        `bson:"description,omitempty" json:"description,omitempty"`   // 群简介
+	Announcement *Announcement `bson:"announcement,omitempty" json:"announcement,omitempty"` // 群公告
+	JoinApproval bool          `bson:"joinApproval" json:"joinApproval"`                     // 加入群聊是否需要管理员审批
+	Public       bool          `bson:"public" json:"public"`                                 // 公开群聊：不通过邀请链接也可以直接加入，否则只能申请
+
+	LastMessage *MessagePreview `bson:"lastMessage,omitempty" json:"lastMessage,omitempty"` // 最后一条消息的摘要，用于聊天列表
+	PairKey     string          `bson:"pairKey,omitempty" json:"-"`                         // 私聊双方 ID 排序后拼接，唯一
+
+	Pins []PinnedMessage `bson:"pins,omitempty" json:"pins,omitempty"` // 置顶的消息，按置顶时间正序
+
+	UnreadCount  int64 `bson:"-" json:"unreadCount"`  // 当前用户的未读消息数，查询时计算
+	MentionCount int64 `bson:"-" json:"mentionCount"` // 未读消息中 @ 当前用户的消息数，查询时计算
+}
+
+// PrivatePairKey 返回私聊双方的唯一键，与双方顺序无关
+func PrivatePairKey(userID1, userID2 string) string {
+	if userID1 > userID2 {
+		userID1, userID2 = userID2, userID1
+	}
+	return userID1 + ":" + userID2
+}
+
+// MessagePreview 聊天列表中展示的最后一条消息摘要
+type MessagePreview struct {
+	ID        string      `bson:"id" json:"id"`
+	SenderID  string      `bson:"senderId" json:"senderId"`
+	Type      MessageType `bson:"type" json:"type"`
+	Text      string      `bson:"text" json:"text"` // 截断后的文本
+	CreatedAt time.Time   `bson:"createdAt" json:"createdAt"`
+	Deleted   bool        `bson:"deleted,omitempty" json:"deleted,omitempty"` // 消息已被撤回
+}
+
+// ChatListQuery 按最后活跃时间倒序分页查询用户的聊天
+type ChatListQuery struct {
+	UserID   string
+	BeforeAt time.Time // 上一页最后一个聊天的最后活跃时间
+	BeforeID string    // 上一页最后一个聊天的 ID，用于同一时间的聊天排序；为空表示第一页
+	Limit    int
+
+	Archived bool  // true 时只返回已归档的聊天，否则不包括已归档的聊天
+	Pinned   *bool // 按是否置顶过滤，nil 表示不过滤
+}
+
+// Announcement 群公告
+type Announcement struct {
+	Text      string    `bson:"text" json:"text"`
+	UpdatedBy string    `bson:"updatedBy" json:"updatedBy"`
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// ChatSettingsUpdate 群设置的部分更新，nil 字段不修改；Announcement 的 Text 为空时清除公告
+type ChatSettingsUpdate struct {
+	Title        *string
+	Avatar       *string
+	Description  *string
+	Announcement *Announcement
+	JoinApproval *bool
+	Public       *bool
 }
 
 // ChatMember 表示聊天成员的结构
 type ChatMember struct {
 	UserID   primitive.ObjectID `bson:"userId" json:"userId"`     // 成员 ID
-	Role     string             `bson:"role" json:"role"`         // 成员角色 ('owner', 'member')
+	Role     string             `bson:"role" json:"role"`         // 成员角色 ('owner', 'admin', 'member')
 	JoinedAt time.Time          `bson:"joinedAt" json:"joinedAt"` // 加入时间
+
+	LastReadMessageID string     `bson:"lastReadMessageId,omitempty" json:"lastReadMessageId,omitempty"` // 已读到的消息 ID
+	LastReadAt        *time.Time `bson:"lastReadAt,omitempty" json:"lastReadAt,omitempty"`               // 已读到的消息的发送时间
+
+	// 成员自己的聊天偏好，其他成员不可见
+	PinnedAt   *time.Time `bson:"pinnedAt,omitempty" json:"pinnedAt,omitempty"`     // 置顶时间
+	Muted      bool       `bson:"muted,omitempty" json:"muted,omitempty"`           // 免打扰
+	MutedUntil *time.Time `bson:"mutedUntil,omitempty" json:"mutedUntil,omitempty"` // 免打扰截止时间，为空表示一直免打扰
+	ArchivedAt *time.Time `bson:"archivedAt,omitempty" json:"archivedAt,omitempty"` // 归档时间
+}
+
+// IsMuted 成员在给定时间是否处于免打扰状态
+func (m *ChatMember) IsMuted(now time.Time) bool {
+	return m.Muted && (m.MutedUntil == nil || now.Before(*m.MutedUntil))
+}
+
+// HidePreferences 清除成员的聊天偏好，返回给其他成员时使用
+func (m *ChatMember) HidePreferences() {
+	m.PinnedAt = nil
+	m.Muted = false
+	m.MutedUntil = nil
+	m.ArchivedAt = nil
+}
+
+// MemberPreferencesUpdate 成员聊天偏好的部分更新，nil 字段不修改
+type MemberPreferencesUpdate struct {
+	Pinned     *bool
+	Muted      *bool
+	MutedUntil *time.Time // 仅在 Muted 为 true 时有效，为空表示一直免打扰
+	Archived   *bool
+}
+
+// ReadReceipt 成员已读位置的变化，用于广播已读回执
+type ReadReceipt struct {
+	ChatID    string    `json:"chatId"`
+	UserID    string    `json:"userId"`
+	MessageID string    `json:"messageId"`
+	ReadAt    time.Time `json:"readAt"` // 已读到的消息的发送时间
+}
+
+// 成员变化类型
+const (
+	MemberAdded            = "added"
+	MemberRemoved          = "removed"
+	MemberLeft             = "left"
+	MemberRoleChanged      = "role_changed"
+	MemberOwnerTransferred = "owner_transferred"
+	MemberJoined           = "joined"
+)
+
+// MemberEvent 群成员变化，用于实时推送
+type MemberEvent struct {
+	ChatID     string   `json:"chatId"`
+	Action     string   `json:"action"`
+	OperatorID string   `json:"operatorId"`
+	UserIDs    []string `json:"userIds"`        // 受影响的用户
+	Role       string   `json:"role,omitempty"` // 角色变化后的新角色
 }
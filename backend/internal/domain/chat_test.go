@@ -0,0 +1,26 @@
+package domain
+
+import "testing"
+
+func TestPrivatePairKey(t *testing.T) {
+	tests := []struct {
+		name             string
+		userID1, userID2 string
+		want             string
+	}{
+		{name: "ordered", userID1: "65f0000000000000000000a1", userID2: "65f0000000000000000000b2", want: "65f0000000000000000000a1:65f0000000000000000000b2"},
+		{name: "reversed", userID1: "65f0000000000000000000b2", userID2: "65f0000000000000000000a1", want: "65f0000000000000000000a1:65f0000000000000000000b2"},
+		{name: "same user", userID1: "65f0000000000000000000a1", userID2: "65f0000000000000000000a1", want: "65f0000000000000000000a1:65f0000000000000000000a1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PrivatePairKey(tt.userID1, tt.userID2); got != tt.want {
+				t.Errorf("PrivatePairKey(%s, %s) = %s, want %s", tt.userID1, tt.userID2, got, tt.want)
+			}
+			if got := PrivatePairKey(tt.userID2, tt.userID1); got != tt.want {
+				t.Errorf("PrivatePairKey is not symmetric: got %s for swapped arguments", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 好友申请状态
+const (
+	FriendRequestPending  = "pending"
+	FriendRequestAccepted = "accepted"
+	FriendRequestDeclined = "declined"
+)
+
+// FriendRequest 好友申请
+type FriendRequest struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	FromUserID string             `bson:"fromUserId" json:"fromUserId"` // 申请人
+	ToUserID   string             `bson:"toUserId" json:"toUserId"`     // 被申请人
+	Message    string             `bson:"message,omitempty" json:"message,omitempty"`
+	Status     string             `bson:"status" json:"status"` // 'pending', 'accepted', 'declined'
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	HandledAt  *time.Time         `bson:"handledAt,omitempty" json:"handledAt,omitempty"`
+}
+
+// Contact 联系人关系，好友双方各保存一条
+type Contact struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	UserID    string             `bson:"userId" json:"userId"`
+	ContactID string             `bson:"contactId" json:"contactId"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// Block 屏蔽关系，被屏蔽的用户不能向屏蔽者发起私聊或发送消息
+type Block struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	UserID    string             `bson:"userId" json:"userId"`       // 屏蔽者
+	BlockedID string             `bson:"blockedId" json:"blockedId"` // 被屏蔽的用户
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChatInvite 群聊邀请链接
+type ChatInvite struct {
+	ID               primitive.ObjectID `bson:"_id" json:"id"`
+	ChatID           string             `bson:"chatId" json:"chatId"`
+	Token            string             `bson:"token" json:"token"` // 邀请链接中的随机令牌
+	CreatedBy        string             `bson:"createdBy" json:"createdBy"`
+	CreatedAt        time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt        *time.Time         `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"` // 为空表示永不过期
+	MaxUses          int                `bson:"maxUses" json:"maxUses"`                         // 0 表示不限次数
+	UseCount         int                `bson:"useCount" json:"useCount"`                       // 已使用次数
+	RequiresApproval bool               `bson:"requiresApproval" json:"requiresApproval"`       // 通过链接加入是否需要管理员审批
+	RevokedAt        *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+// Active 邀请链接在给定时间是否仍可使用
+func (i *ChatInvite) Active(now time.Time) bool {
+	if i.RevokedAt != nil {
+		return false
+	}
+	if i.ExpiresAt != nil && !now.Before(*i.ExpiresAt) {
+		return false
+	}
+	return i.MaxUses == 0 || i.UseCount < i.MaxUses
+}
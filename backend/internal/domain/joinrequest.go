@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 入群申请状态
+const (
+	JoinRequestPending  = "pending"
+	JoinRequestApproved = "approved"
+	JoinRequestRejected = "rejected"
+)
+
+// JoinRequest 需要管理员审批的入群申请
+type JoinRequest struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	ChatID    string             `bson:"chatId" json:"chatId"`
+	UserID    string             `bson:"userId" json:"userId"`                         // 申请人
+	InviteID  string             `bson:"inviteId,omitempty" json:"inviteId,omitempty"` // 通过邀请链接申请时的链接 ID
+	Message   string             `bson:"message,omitempty" json:"message,omitempty"`   // 申请留言
+	Status    string             `bson:"status" json:"status"`                         // 'pending', 'approved', 'rejected'
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	HandledBy string             `bson:"handledBy,omitempty" json:"handledBy,omitempty"` // 处理的管理员
+	HandledAt *time.Time         `bson:"handledAt,omitempty" json:"handledAt,omitempty"`
+}
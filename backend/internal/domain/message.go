@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -12,6 +13,8 @@ const (
 	TextMessage MessageType = "text"
 	CodeMessage MessageType = "code"
 	FileMessage MessageType = "file"
+	// 系统消息，例如成员变化通知，内容为 Text
+	SystemMessage MessageType = "system"
 )
 
 type Message struct {
@@ -21,6 +24,67 @@ type Message struct {
 	Type      MessageType        `bson:"type" json:"type"`
 	Content   MessageContent     `bson:"content" json:"content"`
 	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	Seq       int64              `bson:"seq,omitempty" json:"seq,omitempty"` // 聊天内递增的序号，按分配顺序而不是保存顺序，可能有空缺
+
+	ClientMsgID string `bson:"clientMsgId,omitempty" json:"clientMsgId,omitempty"` // 客户端生成的消息 ID，与 SenderID 唯一，用于去重
+
+	ReplyTo      string     `bson:"replyTo,omitempty" json:"replyTo,omitempty"`           // 引用的消息 ID
+	ThreadRootID string     `bson:"threadRootId,omitempty" json:"threadRootId,omitempty"` // 所属话题的根消息 ID
+	ReplyCount   int64      `bson:"replyCount,omitempty" json:"replyCount,omitempty"`     // 话题回复数（仅根消息）
+	LastReplyAt  *time.Time `bson:"lastReplyAt,omitempty" json:"lastReplyAt,omitempty"`   // 话题最后回复时间（仅根消息）
+
+	Reactions []Reaction `bson:"reactions,omitempty" json:"reactions,omitempty"` // 按表情聚合的回应
+
+	Mentions     []string `bson:"mentions,omitempty" json:"mentions,omitempty"`         // 被 @ 的用户 ID，@all/@here 时为展开后的成员
+	MentionScope string   `bson:"mentionScope,omitempty" json:"mentionScope,omitempty"` // 'all' 或 'here'，没有 @all/@here 时为空
+
+	ForwardedFrom *ForwardSource `bson:"forwardedFrom,omitempty" json:"forwardedFrom,omitempty"` // 转发的消息记录原始来源
+
+	EditedAt    *time.Time    `bson:"editedAt,omitempty" json:"editedAt,omitempty"`       // 最后编辑时间
+	EditHistory []MessageEdit `bson:"editHistory,omitempty" json:"editHistory,omitempty"` // 编辑历史（旧版本）
+	DeletedAt   *time.Time    `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"`     // 撤回/删除时间，非空表示墓碑消息
+	DeletedBy   string        `bson:"deletedBy,omitempty" json:"deletedBy,omitempty"`     // 执行撤回/删除的用户 ID
+}
+
+// @all 和 @here 的提及范围
+const (
+	MentionAll  = "all"  // 所有成员
+	MentionHere = "here" // 当前在线的成员
+)
+
+// ForwardSource 被转发消息的原始来源，多次转发时保留最初的来源
+type ForwardSource struct {
+	ChatID    string    `bson:"chatId" json:"chatId"`
+	MessageID string    `bson:"messageId" json:"messageId"`
+	SenderID  string    `bson:"senderId" json:"senderId"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"` // 原始消息的发送时间
+}
+
+// UnreadCounts 某个聊天中的未读消息数和其中 @ 当前用户的消息数
+type UnreadCounts struct {
+	Unread   int64 `bson:"count"`
+	Mentions int64 `bson:"mentions"`
+}
+
+// Reaction 某个表情的回应聚合，每个用户对同一表情只能回应一次
+type Reaction struct {
+	Emoji   string   `bson:"emoji" json:"emoji"`
+	UserIDs []string `bson:"userIds" json:"userIds"`
+	Count   int      `bson:"count" json:"count"`
+}
+
+// ReactionUpdate 一次回应的增量变化，用于广播
+type ReactionUpdate struct {
+	MessageID string `json:"messageId"`
+	Emoji     string `json:"emoji"`
+	UserID    string `json:"userId"`
+	Added     bool   `json:"added"` // true 为添加，false 为移除
+}
+
+// MessageEdit 记录消息被编辑前的一个版本
+type MessageEdit struct {
+	Content  MessageContent `bson:"content" json:"content"`
+	EditedAt time.Time      `bson:"editedAt" json:"editedAt"`
 }
 
 type MessageContent struct {
@@ -34,3 +98,38 @@ type Code struct {
 	Language string `bson:"language" json:"language"`
 	Content  string `bson:"content" json:"content"`
 }
+
+// previewLength 聊天列表中消息摘要的最大字符数
+const previewLength = 60
+
+// Preview 生成用于聊天列表的消息摘要
+func (m *Message) Preview() *MessagePreview {
+	preview := &MessagePreview{
+		ID:        m.ID.Hex(),
+		SenderID:  m.SenderID,
+		Type:      m.Type,
+		CreatedAt: m.CreatedAt,
+		Deleted:   m.DeletedAt != nil,
+	}
+	switch {
+	case m.DeletedAt != nil:
+		preview.Text = "[消息已撤回]"
+	case m.Type == CodeMessage && m.Content.Code != nil:
+		preview.Text = "[代码] " + m.Content.Code.Content
+	case m.Type == FileMessage:
+		preview.Text = "[文件] " + m.Content.FileName
+	default:
+		preview.Text = m.Content.Text
+	}
+	preview.Text = truncate(strings.Join(strings.Fields(preview.Text), " "), previewLength)
+	return preview
+}
+
+// truncate 按字符截断文本，超出时以省略号结尾
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// PinnedMessage 聊天中被置顶的消息
+type PinnedMessage struct {
+	MessageID string    `bson:"messageId" json:"messageId"`
+	PinnedBy  string    `bson:"pinnedBy" json:"pinnedBy"` // 执行置顶的用户 ID
+	PinnedAt  time.Time `bson:"pinnedAt" json:"pinnedAt"`
+
+	Message *Message `bson:"-" json:"message,omitempty"` // 置顶列表中填充的消息内容
+}
+
+// PinUpdate 置顶或取消置顶的变化，用于广播
+type PinUpdate struct {
+	MessageID  string         `json:"messageId"`
+	OperatorID string         `json:"operatorId"`
+	Pinned     bool           `json:"pinned"`        // true 为置顶，false 为取消置顶
+	Pin        *PinnedMessage `json:"pin,omitempty"` // 仅置顶时，包含消息内容
+}
@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 定时消息状态
+const (
+	ScheduledPending  = "pending"  // 等待投递
+	ScheduledSending  = "sending"  // 已被投递任务领取
+	ScheduledSent     = "sent"     // 已投递
+	ScheduledCanceled = "canceled" // 已取消
+	ScheduledFailed   = "failed"   // 投递失败，例如发送者已不在聊天中，或多次重试仍失败
+)
+
+// ScheduledMessage 在 SendAt 时间投递到聊天的消息
+type ScheduledMessage struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	ChatID    string             `bson:"chatId" json:"chatId"`
+	SenderID  string             `bson:"senderId" json:"senderId"`
+	Type      MessageType        `bson:"type" json:"type"`
+	Content   MessageContent     `bson:"content" json:"content"`
+	SendAt    time.Time          `bson:"sendAt" json:"sendAt"`
+	Status    string             `bson:"status" json:"status"` // 'pending', 'sending', 'sent', 'canceled', 'failed'
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+
+	ClaimedAt *time.Time `bson:"claimedAt,omitempty" json:"-"`                   // 被投递任务领取的时间，用于恢复中断的投递
+	MessageID string     `bson:"messageId,omitempty" json:"messageId,omitempty"` // 投递后生成的消息 ID
+	SentAt    *time.Time `bson:"sentAt,omitempty" json:"sentAt,omitempty"`
+	Error     string     `bson:"error,omitempty" json:"error,omitempty"` // 投递失败的原因，重试时为上一次失败的原因
+
+	Attempts      int        `bson:"attempts,omitempty" json:"attempts,omitempty"` // 因临时错误投递失败的次数
+	NextAttemptAt *time.Time `bson:"nextAttemptAt,omitempty" json:"-"`             // 临时错误后下一次重试的时间，早于该时间不会被领取
+}
+
+// ClientMsgID 投递时使用的客户端消息 ID，中断后重新投递不会产生重复消息
+func (m *ScheduledMessage) ClientMsgID() string {
+	return "scheduled:" + m.ID.Hex()
+}
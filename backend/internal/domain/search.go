@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// MessageSearchQuery 消息全文检索条件，结果按发送时间倒序
+type MessageSearchQuery struct {
+	Keyword  string
+	ChatIDs  []string      // 只在这些聊天中检索，由服务层限定为用户所在的聊天
+	SenderID string        // 为空表示不限发送者
+	Types    []MessageType // 只检索这些类型的消息
+	From     *time.Time    // 发送时间下限（含）
+	To       *time.Time    // 发送时间上限（不含）
+
+	BeforeAt time.Time // 上一页最后一条消息的发送时间
+	BeforeID string    // 上一页最后一条消息的 ID，为空表示第一页
+	Limit    int
+}
+
+// MessageSearchHit 一条检索结果，Highlights 为 Snippet 中匹配关键字的位置（按字符计）
+type MessageSearchHit struct {
+	Message    *Message    `json:"message"`
+	Snippet    string      `json:"snippet"`
+	Highlights []TextRange `json:"highlights"`
+}
+
+// TextRange 文本中的一段区间 [Start, End)，按字符计
+type TextRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
@@ -16,6 +16,8 @@ type User struct {
 	Profile   Profile            `bson:"profile" json:"profile"`
 	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+
+	Privacy Privacy `bson:"privacy" json:"privacy"` // 隐私设置
 }
 
 type UserStatus struct {
@@ -27,3 +29,19 @@ type Profile struct {
 	Nickname string `bson:"nickname" json:"nickname"`
 	Bio      string `bson:"bio" json:"bio"`
 }
+
+// 谁可以向用户发起私聊
+const (
+	MessageFromEveryone = "everyone" // 所有人
+	MessageFromContacts = "contacts" // 仅联系人
+)
+
+// Privacy 用户的隐私设置
+type Privacy struct {
+	WhoCanMessage string `bson:"whoCanMessage,omitempty" json:"whoCanMessage"` // 为空时视为 'everyone'
+}
+
+// AllowsStrangerChat 非联系人是否可以发起私聊
+func (p Privacy) AllowsStrangerChat() bool {
+	return p.WhoCanMessage != MessageFromContacts
+}
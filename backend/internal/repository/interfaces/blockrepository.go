@@ -0,0 +1,19 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+)
+
+type BlockRepository interface {
+	// 屏蔽用户，已屏蔽时不报错
+	Block(ctx context.Context, userID, blockedID string, createdAt time.Time) error
+	// 取消屏蔽，未屏蔽时返回 false
+	Unblock(ctx context.Context, userID, blockedID string) (bool, error)
+	// userID 是否屏蔽了 blockedID
+	IsBlocked(ctx context.Context, userID, blockedID string) (bool, error)
+	// 获取用户屏蔽的用户 ID
+	ListBlockedIDs(ctx context.Context, userID string) ([]string, error)
+	// 获取屏蔽了该用户的用户 ID
+	ListBlockerIDs(ctx context.Context, blockedID string) ([]string, error)
+}
@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"github.com/baoerzuikeai/Imsystem/internal/domain"
 )
@@ -25,11 +26,46 @@ type ChatRepository interface {
 	// 根据用户ID和聊天类型查询聊天
 	GetChatsByUserAndType(ctx context.Context, userID string, chatType string) ([]*domain.Chat, error)
 	
-	//添加聊私聊成员
-	CreatePrivateChat(ctx context.Context, chat *domain.Chat) error
+	// 原子地获取或创建私聊：双方已有私聊时返回已有的聊天和 false，否则保存 chat 并返回 true
+	GetOrCreatePrivateChat(ctx context.Context, chat *domain.Chat) (*domain.Chat, bool, error)
+	// 根据双方的唯一键获取私聊
+	GetPrivateChatByPairKey(ctx context.Context, pairKey string) (*domain.Chat, error)
 
 	//获取chat
 	GetAllChatsByUserID(ctx context.Context, userID string) ([]*domain.Chat, error)
 
 	CreateGroupChat(ctx context.Context, chat *domain.Chat) error
+
+	// 推进成员的已读位置，只有比当前位置新时才更新，更新成功返回 true
+	UpdateReadState(ctx context.Context, chatID, userID, messageID string, readAt time.Time) (bool, error)
+
+	// 原子地为聊天分配下一个消息序号
+	NextSeq(ctx context.Context, chatID string) (int64, error)
+
+	// 添加成员，用户已是成员时返回 false
+	AddMember(ctx context.Context, chatID string, member domain.ChatMember) (bool, error)
+	// 移除成员，用户不是成员时返回 false
+	RemoveMember(ctx context.Context, chatID, userID string) (bool, error)
+	// 修改成员角色，用户不是成员时返回 false
+	UpdateMemberRole(ctx context.Context, chatID, userID, role string) (bool, error)
+	// 原子地将群主转让给另一成员，原群主变为管理员
+	TransferOwnership(ctx context.Context, chatID, fromUserID, toUserID string) (bool, error)
+
+	// 更新群设置并返回更新后的聊天
+	UpdateSettings(ctx context.Context, chatID string, update domain.ChatSettingsUpdate) (*domain.Chat, error)
+
+	// 按最后活跃时间倒序分页获取用户的聊天
+	ListChats(ctx context.Context, query domain.ChatListQuery) ([]*domain.Chat, error)
+	// 更新最后活跃时间和最后一条消息摘要，只在消息比当前记录新时更新
+	UpdateLastMessage(ctx context.Context, chatID string, preview *domain.MessagePreview) error
+	// 最后一条消息被编辑或撤回时刷新摘要，不是最后一条消息时不更新
+	RefreshLastMessage(ctx context.Context, chatID string, preview *domain.MessagePreview) error
+
+	// 更新成员的置顶、免打扰和归档设置，用户不是成员时返回 false
+	UpdateMemberPreferences(ctx context.Context, chatID, userID string, update domain.MemberPreferencesUpdate, now time.Time) (bool, error)
+
+	// 置顶消息，消息已置顶或置顶数已达到 limit 时返回 false
+	AddPin(ctx context.Context, chatID string, pin domain.PinnedMessage, limit int) (bool, error)
+	// 取消置顶，消息没有置顶时返回 false
+	RemovePin(ctx context.Context, chatID, messageID string) (bool, error)
 }
@@ -0,0 +1,27 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+)
+
+type ContactRepository interface {
+	// 创建好友申请，已有相同方向的待处理申请时返回已有的申请和 false
+	CreateRequest(ctx context.Context, request *domain.FriendRequest) (*domain.FriendRequest, bool, error)
+	GetRequestByID(ctx context.Context, id string) (*domain.FriendRequest, error)
+	// 获取 fromUserID 发给 toUserID 的待处理申请
+	GetPendingRequest(ctx context.Context, fromUserID, toUserID string) (*domain.FriendRequest, error)
+	// 获取用户收到（incoming 为 true）或发出的待处理申请
+	ListPendingRequests(ctx context.Context, userID string, incoming bool) ([]*domain.FriendRequest, error)
+	// 将待处理的申请标记为已接受或已拒绝，申请已被处理时返回 false
+	ResolveRequest(ctx context.Context, id, status string, handledAt time.Time) (bool, error)
+
+	// 双向添加联系人，已是联系人时不报错
+	AddContact(ctx context.Context, userID, contactID string, createdAt time.Time) error
+	// 双向移除联系人，不是联系人时返回 false
+	RemoveContact(ctx context.Context, userID, contactID string) (bool, error)
+	IsContact(ctx context.Context, userID, contactID string) (bool, error)
+	ListContactIDs(ctx context.Context, userID string) ([]string, error)
+}
@@ -0,0 +1,22 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+)
+
+type InviteRepository interface {
+	Create(ctx context.Context, invite *domain.ChatInvite) error
+	GetByToken(ctx context.Context, token string) (*domain.ChatInvite, error)
+
+	// 获取聊天中在给定时间仍可使用的邀请链接
+	ListActive(ctx context.Context, chatID string, now time.Time) ([]*domain.ChatInvite, error)
+
+	// 撤销邀请链接，链接不存在或已撤销时返回 false
+	Revoke(ctx context.Context, chatID, inviteID string, revokedAt time.Time) (bool, error)
+
+	// 原子地占用一次使用次数，链接已失效时返回 false
+	Use(ctx context.Context, inviteID string, now time.Time) (bool, error)
+}
@@ -0,0 +1,20 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+)
+
+type JoinRequestRepository interface {
+	// 创建申请，用户在该聊天已有待处理的申请时返回已有的申请和 false
+	Create(ctx context.Context, request *domain.JoinRequest) (*domain.JoinRequest, bool, error)
+	GetByID(ctx context.Context, id string) (*domain.JoinRequest, error)
+
+	// 获取聊天中待处理的申请，按申请时间排序
+	ListPending(ctx context.Context, chatID string) ([]*domain.JoinRequest, error)
+
+	// 将待处理的申请标记为已通过或已拒绝，申请已被处理时返回 false
+	Resolve(ctx context.Context, id, status, handledBy string, handledAt time.Time) (bool, error)
+}
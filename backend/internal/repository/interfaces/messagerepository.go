@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"github.com/baoerzuikeai/Imsystem/internal/domain"
 )
@@ -9,6 +10,31 @@ import (
 type MessageRepository interface {
 	Create(ctx context.Context, message *domain.Message) error
 	GetByChatID(ctx context.Context, chatID string, limit, offset int) ([]*domain.Message, error)
+	// 按序号正序获取 afterSeq 之后的消息
+	GetAfterSeq(ctx context.Context, chatID string, afterSeq int64, limit int) ([]*domain.Message, error)
+	// 获取 anchor 之前的消息，按时间倒序；anchor 为 nil 时从最新消息开始
+	GetBefore(ctx context.Context, chatID string, anchor *domain.Message, limit int) ([]*domain.Message, error)
+	// 获取 anchor 之后的消息，按时间正序
+	GetAfter(ctx context.Context, chatID string, anchor *domain.Message, limit int) ([]*domain.Message, error)
+	GetBySeq(ctx context.Context, chatID string, seq int64) (*domain.Message, error)
 	GetByID(ctx context.Context, id string) (*domain.Message, error)
+	// 批量获取消息，不存在的 ID 会被忽略
+	GetByIDs(ctx context.Context, ids []string) ([]*domain.Message, error)
+	// 根据发送者和客户端消息 ID 查询消息，用于去重
+	GetByClientMsgID(ctx context.Context, senderID, clientMsgID string) (*domain.Message, error)
 	Delete(ctx context.Context, id string) error
+	// 更新消息内容和重新解析的提及，并把旧版本追加到编辑历史
+	UpdateContent(ctx context.Context, id string, content domain.MessageContent, mentions []string, mentionScope string, previous domain.MessageEdit) error
+	// 软删除消息：清空内容并记录删除人和时间，保留文档作为墓碑
+	SoftDelete(ctx context.Context, id string, deletedBy string, deletedAt time.Time) error
+	// 分页获取话题下的回复，按时间正序
+	GetThreadReplies(ctx context.Context, rootID string, limit, offset int) ([]*domain.Message, error)
+	// 根消息回复数加一并更新最后回复时间
+	IncrementReplyCount(ctx context.Context, rootID string, repliedAt time.Time) error
+	// 添加表情回应，用户已回应过该表情时返回 false
+	AddReaction(ctx context.Context, id, emoji, userID string) (bool, error)
+	// 移除表情回应，用户未回应过该表情时返回 false
+	RemoveReaction(ctx context.Context, id, emoji, userID string) (bool, error)
+	// 按聊天统计 since 之后他人发送的未删除消息数，以及其中 @ 了该用户的消息数
+	CountUnread(ctx context.Context, userID string, since map[string]time.Time) (map[string]domain.UnreadCounts, error)
 }
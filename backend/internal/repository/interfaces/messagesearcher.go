@@ -0,0 +1,18 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+)
+
+// MessageSearcher 消息全文检索。默认实现基于 MongoDB 文本索引，由数据库自动维护；
+// 换成嵌入式索引（如 bleve）时在 Index/Remove 中同步更新索引
+type MessageSearcher interface {
+	// 按条件检索未删除的消息，按发送时间倒序
+	Search(ctx context.Context, query domain.MessageSearchQuery) ([]*domain.Message, error)
+	// 消息创建或编辑后更新索引
+	Index(ctx context.Context, message *domain.Message) error
+	// 消息撤回或删除后从索引中移除
+	Remove(ctx context.Context, messageID string) error
+}
@@ -0,0 +1,30 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+)
+
+type ScheduledMessageRepository interface {
+	Create(ctx context.Context, message *domain.ScheduledMessage) error
+	GetByID(ctx context.Context, id string) (*domain.ScheduledMessage, error)
+	// 获取用户待投递的定时消息，按投递时间排序；chatID 为空时不限聊天
+	ListPending(ctx context.Context, senderID, chatID string) ([]*domain.ScheduledMessage, error)
+	// 修改待投递的定时消息的内容或投递时间，nil 表示不修改；不存在或已不是待投递时返回 mongo.ErrNoDocuments
+	UpdatePending(ctx context.Context, id, senderID string, content *domain.MessageContent, sendAt *time.Time, now time.Time) (*domain.ScheduledMessage, error)
+	// 取消待投递的定时消息，不存在或已不是待投递时返回 false
+	Cancel(ctx context.Context, id, senderID string, now time.Time) (bool, error)
+
+	// 原子地领取一条到期的待投递消息，没有到期消息时返回 mongo.ErrNoDocuments
+	ClaimDue(ctx context.Context, now time.Time) (*domain.ScheduledMessage, error)
+	// 将领取时间早于 before 的投递中消息恢复为待投递，用于服务中断后重新投递
+	ReleaseStale(ctx context.Context, before time.Time) (int64, error)
+	// 标记为已投递
+	MarkSent(ctx context.Context, id, messageID string, sentAt time.Time) error
+	// 标记为投递失败
+	MarkFailed(ctx context.Context, id, reason string, now time.Time) error
+	// 投递遇到临时错误时恢复为待投递，失败次数加一，nextAttempt 之前不会再被领取
+	Retry(ctx context.Context, id, reason string, nextAttempt, now time.Time) error
+}
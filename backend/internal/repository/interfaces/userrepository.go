@@ -9,9 +9,19 @@ import (
 type UserRepository interface {
 	Create(ctx context.Context, user *domain.User) error
 	GetByID(ctx context.Context, id string) (*domain.User, error)
+	// 批量获取用户，不存在的 ID 会被忽略
+	GetByIDs(ctx context.Context, ids []string) ([]*domain.User, error)
 	GetByEmail(ctx context.Context, email string) (*domain.User, error)
 	GetByUsername(ctx context.Context, username string) (*domain.User, error)
+	// 批量按用户名获取用户，不存在的用户名会被忽略
+	GetByUsernames(ctx context.Context, usernames []string) ([]*domain.User, error)
 	Update(ctx context.Context, user *domain.User) error
 	Delete(ctx context.Context, id string) error
 	SearchUsers(ctx context.Context, keyword string) ([]*domain.User, error)
+	// 只更新在线状态，不覆盖其它字段
+	UpdateStatus(ctx context.Context, id string, status domain.UserStatus) error
+	// 将所有在线用户重置为离线，服务启动时使用
+	ResetOnlineStatus(ctx context.Context) error
+	// 只更新隐私设置
+	UpdatePrivacy(ctx context.Context, id string, privacy domain.Privacy) error
 }
\ No newline at end of file
@@ -0,0 +1,99 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type blockRepository struct {
+	collection *mongo.Collection
+}
+
+// NewBlockRepository 创建一个新的 BlockRepository 实例
+func NewBlockRepository(db *mongo.Database) *blockRepository {
+	return &blockRepository{
+		collection: db.Collection("blocks"),
+	}
+}
+
+// EnsureIndexes 创建屏蔽关系集合需要的索引
+func (r *blockRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "blockedId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "blockedId", Value: 1}},
+		},
+	})
+	return err
+}
+
+func (r *blockRepository) Block(ctx context.Context, userID, blockedID string, createdAt time.Time) error {
+	filter := bson.M{"userId": userID, "blockedId": blockedID}
+	update := bson.M{"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "createdAt": createdAt}}
+	_, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *blockRepository) Unblock(ctx context.Context, userID, blockedID string) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"userId": userID, "blockedId": blockedID})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (r *blockRepository) IsBlocked(ctx context.Context, userID, blockedID string) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"userId": userID, "blockedId": blockedID}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *blockRepository) ListBlockedIDs(ctx context.Context, userID string) ([]string, error) {
+	blocks, err := r.find(ctx, bson.M{"userId": userID})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		ids = append(ids, block.BlockedID)
+	}
+	return ids, nil
+}
+
+func (r *blockRepository) ListBlockerIDs(ctx context.Context, blockedID string) ([]string, error) {
+	blocks, err := r.find(ctx, bson.M{"blockedId": blockedID})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		ids = append(ids, block.UserID)
+	}
+	return ids, nil
+}
+
+func (r *blockRepository) find(ctx context.Context, filter bson.M) ([]domain.Block, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var blocks []domain.Block
+	if err := cursor.All(ctx, &blocks); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
@@ -2,7 +2,8 @@ package mongodb
 
 import (
 	"context"
-	"errors"
+	"strconv"
+	"time"
 
 	"github.com/baoerzuikeai/Imsystem/internal/domain"
 	"go.mongodb.org/mongo-driver/bson"
@@ -24,6 +25,82 @@ func NewChatRepository(db *mongo.Database) *chatRepository {
 	}
 }
 
+// EnsureIndexes 创建聊天集合需要的索引
+func (r *chatRepository) EnsureIndexes(ctx context.Context) error {
+	if err := r.backfillLastMessageAt(ctx); err != nil {
+		return err
+	}
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// 聊天列表按最后活跃时间分页
+			Keys: bson.D{{Key: "members.userId", Value: 1}, {Key: "lastMessageAt", Value: -1}, {Key: "_id", Value: -1}},
+		},
+		{
+			// 同一对用户只能有一个私聊
+			Keys: bson.D{{Key: "pairKey", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"pairKey": bson.M{"$exists": true}}),
+		},
+	})
+	return err
+}
+
+// backfillLastMessageAt 旧数据中的私聊创建时没有设置最后活跃时间，用创建时间补充
+func (r *chatRepository) backfillLastMessageAt(ctx context.Context) error {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"lastMessageAt": bson.M{"$exists": false}},
+		bson.M{"lastMessageAt": bson.M{"$lte": time.Time{}}},
+	}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{"lastMessageAt": "$createdAt"}}}}
+	_, err := r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// BackfillPairKeys 为旧数据中没有 pairKey 的私聊补充唯一键，需要在 EnsureIndexes 之前调用。
+// 同一对用户的重复私聊只有最早创建的一个获得唯一键，其余标记 duplicatePairKey 后不再处理，返回本次标记的重复私聊数
+func (r *chatRepository) BackfillPairKeys(ctx context.Context) (int, error) {
+	filter := bson.M{
+		"type":             domain.ChatTypePrivate,
+		"pairKey":          bson.M{"$exists": false},
+		"duplicatePairKey": bson.M{"$exists": false},
+	}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	duplicates := 0
+	seen := make(map[string]bool)
+	for cursor.Next(ctx) {
+		var chat domain.Chat
+		if err := cursor.Decode(&chat); err != nil {
+			return duplicates, err
+		}
+		if len(chat.Members) != 2 {
+			continue
+		}
+		pairKey := domain.PrivatePairKey(chat.Members[0].UserID.Hex(), chat.Members[1].UserID.Hex())
+		if !seen[pairKey] {
+			seen[pairKey] = true
+			_, err := r.collection.UpdateOne(ctx, bson.M{"_id": chat.ID}, bson.M{"$set": bson.M{"pairKey": pairKey}})
+			if err == nil {
+				continue
+			}
+			if !mongo.IsDuplicateKeyError(err) {
+				return duplicates, err
+			}
+		}
+		// 已有其他私聊使用该唯一键
+		if _, err := r.collection.UpdateOne(ctx, bson.M{"_id": chat.ID}, bson.M{"$set": bson.M{"duplicatePairKey": pairKey}}); err != nil {
+			return duplicates, err
+		}
+		duplicates++
+	}
+	return duplicates, cursor.Err()
+}
+
 // GetChatMembers 根据 ChatID 获取聊天成员
 func (r *chatRepository) GetChatMembers(ctx context.Context, chatID string) ([]*domain.User, error) {
 	// 将字符串 chatID 转换为 ObjectId
@@ -58,8 +135,13 @@ func (r *chatRepository) GetChatMembers(ctx context.Context, chatID string) ([]*
 
 // GetChatByID 根据 ChatID 获取聊天详情
 func (r *chatRepository) GetChatByID(ctx context.Context, chatID string) (*domain.Chat, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, err
+	}
+
 	var chat domain.Chat
-	err := r.collection.FindOne(ctx, bson.M{"_id": chatID}).Decode(&chat)
+	err = r.collection.FindOne(ctx, bson.M{"_id": chatObjectID}).Decode(&chat)
 	if err != nil {
 		return nil, err
 	}
@@ -112,21 +194,48 @@ func (r *chatRepository) GetChatsByUserAndType(ctx context.Context, userID strin
 	return chats, nil
 }
 
-func (r *chatRepository) CreatePrivateChat(ctx context.Context, chat *domain.Chat) error {
-	// 检查是否已经存在相同成员的私聊
-	filter := bson.M{
-		"type": "private",
-		"$and": []bson.M{
-			{"members": bson.M{"$elemMatch": bson.M{"userId": chat.Members[0].UserID}}},
-			{"members": bson.M{"$elemMatch": bson.M{"userId": chat.Members[1].UserID}}},
-		},
+func (r *chatRepository) GetOrCreatePrivateChat(ctx context.Context, chat *domain.Chat) (*domain.Chat, bool, error) {
+	doc, err := toBsonM(chat)
+	if err != nil {
+		return nil, false, err
 	}
-	existingChat := r.collection.FindOne(ctx, filter)
-	if existingChat.Err() == nil {
-		return errors.New("私聊已存在")
+	// pairKey 由查询条件写入新文档
+	delete(doc, "pairKey")
+
+	filter := bson.M{"pairKey": chat.PairKey}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var result domain.Chat
+	err = r.collection.FindOneAndUpdate(ctx, filter, bson.M{"$setOnInsert": doc}, opts).Decode(&result)
+	if mongo.IsDuplicateKeyError(err) {
+		// 并发的 upsert 同时插入时只有一个成功，另一个读取已创建的聊天
+		existing, err := r.GetPrivateChatByPairKey(ctx, chat.PairKey)
+		return existing, false, err
 	}
-	_, err := r.collection.InsertOne(ctx, chat)
-	return err
+	if err != nil {
+		return nil, false, err
+	}
+	return &result, result.ID == chat.ID, nil
+}
+
+func (r *chatRepository) GetPrivateChatByPairKey(ctx context.Context, pairKey string) (*domain.Chat, error) {
+	var chat domain.Chat
+	if err := r.collection.FindOne(ctx, bson.M{"pairKey": pairKey}).Decode(&chat); err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+// toBsonM 将结构体按 bson 标签转换为 bson.M
+func toBsonM(value interface{}) (bson.M, error) {
+	data, err := bson.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
 }
 
 func (r *chatRepository) GetAllChatsByUserID(ctx context.Context, userID string) ([]*domain.Chat, error) {
@@ -154,4 +263,355 @@ func (r *chatRepository) GetAllChatsByUserID(ctx context.Context, userID string)
 func (r *chatRepository) CreateGroupChat(ctx context.Context, chat *domain.Chat) error {
     _, err := r.collection.InsertOne(ctx, chat)
     return err
-}
\ No newline at end of file
+}
+
+func (r *chatRepository) UpdateReadState(ctx context.Context, chatID, userID, messageID string, readAt time.Time) (bool, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return false, err
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.M{
+		"_id": chatObjectID,
+		"members": bson.M{"$elemMatch": bson.M{
+			"userId": userObjID,
+			"$or": []bson.M{
+				{"lastReadAt": bson.M{"$exists": false}},
+				{"lastReadAt": bson.M{"$lt": readAt}},
+			},
+		}},
+	}
+	update := bson.M{"$set": bson.M{
+		"members.$.lastReadMessageId": messageID,
+		"members.$.lastReadAt":        readAt,
+	}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *chatRepository) NextSeq(ctx context.Context, chatID string) (int64, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return 0, err
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"lastSeq": 1})
+	var result struct {
+		LastSeq int64 `bson:"lastSeq"`
+	}
+	err = r.collection.FindOneAndUpdate(ctx, bson.M{"_id": chatObjectID}, bson.M{"$inc": bson.M{"lastSeq": 1}}, opts).Decode(&result)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastSeq, nil
+}
+
+func (r *chatRepository) AddMember(ctx context.Context, chatID string, member domain.ChatMember) (bool, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.M{"_id": chatObjectID, "members.userId": bson.M{"$ne": member.UserID}}
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$push": bson.M{"members": member}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *chatRepository) RemoveMember(ctx context.Context, chatID, userID string) (bool, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return false, err
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return false, err
+	}
+
+	update := bson.M{"$pull": bson.M{"members": bson.M{"userId": userObjID}}}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": chatObjectID}, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *chatRepository) UpdateMemberRole(ctx context.Context, chatID, userID, role string) (bool, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return false, err
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.M{"_id": chatObjectID, "members.userId": userObjID}
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"members.$.role": role}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *chatRepository) TransferOwnership(ctx context.Context, chatID, fromUserID, toUserID string) (bool, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return false, err
+	}
+	fromObjID, err := primitive.ObjectIDFromHex(fromUserID)
+	if err != nil {
+		return false, err
+	}
+	toObjID, err := primitive.ObjectIDFromHex(toUserID)
+	if err != nil {
+		return false, err
+	}
+
+	// 原群主必须仍是群主，新群主必须是成员，两个角色在同一次更新中修改
+	filter := bson.M{
+		"_id": chatObjectID,
+		"$and": []bson.M{
+			{"members": bson.M{"$elemMatch": bson.M{"userId": fromObjID, "role": domain.RoleOwner}}},
+			{"members.userId": toObjID},
+		},
+	}
+	update := bson.M{"$set": bson.M{
+		"members.$[from].role": domain.RoleAdmin,
+		"members.$[to].role":   domain.RoleOwner,
+	}}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{
+			bson.M{"from.userId": fromObjID},
+			bson.M{"to.userId": toObjID},
+		},
+	})
+	result, err := r.collection.UpdateOne(ctx, filter, update, opts)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *chatRepository) UpdateSettings(ctx context.Context, chatID string, update domain.ChatSettingsUpdate) (*domain.Chat, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	set := bson.M{}
+	unset := bson.M{}
+	if update.Title != nil {
+		set["title"] = *update.Title
+	}
+	if update.Avatar != nil {
+		set["avatar"] = *update.Avatar
+	}
+	if update.Description != nil {
+		set["description"] = *update.Description
+	}
+	if update.Announcement != nil {
+		if update.Announcement.Text == "" {
+			unset["announcement"] = ""
+		} else {
+			set["announcement"] = update.Announcement
+		}
+	}
+	if update.JoinApproval != nil {
+		set["joinApproval"] = *update.JoinApproval
+	}
+	if update.Public != nil {
+		set["public"] = *update.Public
+	}
+
+	changes := bson.M{}
+	if len(set) > 0 {
+		changes["$set"] = set
+	}
+	if len(unset) > 0 {
+		changes["$unset"] = unset
+	}
+	if len(changes) == 0 {
+		return r.GetChatByID(ctx, chatID)
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var chat domain.Chat
+	if err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": chatObjectID}, changes, opts).Decode(&chat); err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+func (r *chatRepository) ListChats(ctx context.Context, query domain.ChatListQuery) ([]*domain.Chat, error) {
+	userObjID, err := primitive.ObjectIDFromHex(query.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	member := bson.M{
+		"userId":     userObjID,
+		"archivedAt": bson.M{"$exists": query.Archived},
+	}
+	if query.Pinned != nil {
+		member["pinnedAt"] = bson.M{"$exists": *query.Pinned}
+	}
+	filter := bson.M{"members": bson.M{"$elemMatch": member}}
+	if query.BeforeID != "" {
+		beforeID, err := primitive.ObjectIDFromHex(query.BeforeID)
+		if err != nil {
+			return nil, err
+		}
+		filter["$or"] = bson.A{
+			bson.M{"lastMessageAt": bson.M{"$lt": query.BeforeAt}},
+			bson.M{"lastMessageAt": query.BeforeAt, "_id": bson.M{"$lt": beforeID}},
+		}
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "lastMessageAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(query.Limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	chats := []*domain.Chat{}
+	if err := cursor.All(ctx, &chats); err != nil {
+		return nil, err
+	}
+	return chats, nil
+}
+
+func (r *chatRepository) UpdateLastMessage(ctx context.Context, chatID string, preview *domain.MessagePreview) error {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{
+		"_id": chatObjectID,
+		"$or": bson.A{
+			bson.M{"lastMessageAt": bson.M{"$lte": preview.CreatedAt}},
+			bson.M{"lastMessageAt": bson.M{"$exists": false}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"lastMessageAt": preview.CreatedAt, "lastMessage": preview}}
+	_, err = r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *chatRepository) RefreshLastMessage(ctx context.Context, chatID string, preview *domain.MessagePreview) error {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": chatObjectID, "lastMessage.id": preview.ID}
+	_, err = r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"lastMessage": preview}})
+	return err
+}
+
+func (r *chatRepository) UpdateMemberPreferences(ctx context.Context, chatID, userID string, update domain.MemberPreferencesUpdate, now time.Time) (bool, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return false, err
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return false, err
+	}
+
+	set := bson.M{}
+	unset := bson.M{}
+	if update.Pinned != nil {
+		if *update.Pinned {
+			set["members.$.pinnedAt"] = now
+		} else {
+			unset["members.$.pinnedAt"] = ""
+		}
+	}
+	if update.Muted != nil {
+		if *update.Muted {
+			set["members.$.muted"] = true
+		} else {
+			unset["members.$.muted"] = ""
+		}
+		if *update.Muted && update.MutedUntil != nil {
+			set["members.$.mutedUntil"] = *update.MutedUntil
+		} else {
+			unset["members.$.mutedUntil"] = ""
+		}
+	}
+	if update.Archived != nil {
+		if *update.Archived {
+			set["members.$.archivedAt"] = now
+		} else {
+			unset["members.$.archivedAt"] = ""
+		}
+	}
+
+	changes := bson.M{}
+	if len(set) > 0 {
+		changes["$set"] = set
+	}
+	if len(unset) > 0 {
+		changes["$unset"] = unset
+	}
+	filter := bson.M{"_id": chatObjectID, "members.userId": userObjID}
+	if len(changes) == 0 {
+		count, err := r.collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+		return count > 0, err
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, changes)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *chatRepository) AddPin(ctx context.Context, chatID string, pin domain.PinnedMessage, limit int) (bool, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return false, err
+	}
+
+	// 重复置顶和数量上限都在同一次更新中判断，并发置顶时不会超出上限
+	filter := bson.M{
+		"_id":                           chatObjectID,
+		"pins.messageId":                bson.M{"$ne": pin.MessageID},
+		"pins." + strconv.Itoa(limit-1): bson.M{"$exists": false}, // 数组中还没有第 limit 个元素
+	}
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$push": bson.M{"pins": pin}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *chatRepository) RemovePin(ctx context.Context, chatID, messageID string) (bool, error) {
+	chatObjectID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return false, err
+	}
+
+	update := bson.M{"$pull": bson.M{"pins": bson.M{"messageId": messageID}}}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": chatObjectID}, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
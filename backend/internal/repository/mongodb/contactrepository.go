@@ -0,0 +1,217 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type contactRepository struct {
+	collection *mongo.Collection
+	requests   *mongo.Collection
+	chats      *mongo.Collection
+	migrations *mongo.Collection
+}
+
+// NewContactRepository 创建一个新的 ContactRepository 实例
+func NewContactRepository(db *mongo.Database) *contactRepository {
+	return &contactRepository{
+		collection: db.Collection("contacts"),
+		requests:   db.Collection("friend_requests"),
+		chats:      db.Collection("chats"),
+		migrations: db.Collection("migrations"),
+	}
+}
+
+// contactsBackfillMigration 记录已为旧私聊补充联系人的迁移 ID
+const contactsBackfillMigration = "contacts_from_private_chats"
+
+// BackfillFromPrivateChats 为引入联系人之前已存在的私聊双方建立联系人关系，返回处理的私聊数。
+// 只执行一次，之后用户删除的联系人不会被重新添加
+func (r *contactRepository) BackfillFromPrivateChats(ctx context.Context) (int, error) {
+	count, err := r.migrations.CountDocuments(ctx, bson.M{"_id": contactsBackfillMigration}, options.Count().SetLimit(1))
+	if err != nil || count > 0 {
+		return 0, err
+	}
+
+	cursor, err := r.chats.Find(ctx, bson.M{"type": domain.ChatTypePrivate})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	backfilled := 0
+	for cursor.Next(ctx) {
+		var chat domain.Chat
+		if err := cursor.Decode(&chat); err != nil {
+			return backfilled, err
+		}
+		if len(chat.Members) != 2 {
+			continue
+		}
+		if err := r.AddContact(ctx, chat.Members[0].UserID.Hex(), chat.Members[1].UserID.Hex(), chat.CreatedAt); err != nil {
+			return backfilled, err
+		}
+		backfilled++
+	}
+	if err := cursor.Err(); err != nil {
+		return backfilled, err
+	}
+
+	_, err = r.migrations.InsertOne(ctx, bson.M{"_id": contactsBackfillMigration, "appliedAt": time.Now()})
+	return backfilled, err
+}
+
+// EnsureIndexes 创建联系人和好友申请集合需要的索引
+func (r *contactRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "contactId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.requests.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// 同一方向只能有一个待处理的申请
+			Keys: bson.D{{Key: "fromUserId", Value: 1}, {Key: "toUserId", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": domain.FriendRequestPending}),
+		},
+		{
+			Keys: bson.D{{Key: "toUserId", Value: 1}, {Key: "status", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+	})
+	return err
+}
+
+func (r *contactRepository) CreateRequest(ctx context.Context, request *domain.FriendRequest) (*domain.FriendRequest, bool, error) {
+	if request.ID.IsZero() {
+		request.ID = primitive.NewObjectID()
+	}
+	_, err := r.requests.InsertOne(ctx, request)
+	if err == nil {
+		return request, true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, false, err
+	}
+
+	existing, err := r.GetPendingRequest(ctx, request.FromUserID, request.ToUserID)
+	if err != nil {
+		return nil, false, err
+	}
+	return existing, false, nil
+}
+
+func (r *contactRepository) GetRequestByID(ctx context.Context, id string) (*domain.FriendRequest, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var request domain.FriendRequest
+	if err := r.requests.FindOne(ctx, bson.M{"_id": objectID}).Decode(&request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *contactRepository) GetPendingRequest(ctx context.Context, fromUserID, toUserID string) (*domain.FriendRequest, error) {
+	var request domain.FriendRequest
+	filter := bson.M{"fromUserId": fromUserID, "toUserId": toUserID, "status": domain.FriendRequestPending}
+	if err := r.requests.FindOne(ctx, filter).Decode(&request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *contactRepository) ListPendingRequests(ctx context.Context, userID string, incoming bool) ([]*domain.FriendRequest, error) {
+	filter := bson.M{"fromUserId": userID, "status": domain.FriendRequestPending}
+	if incoming {
+		filter = bson.M{"toUserId": userID, "status": domain.FriendRequestPending}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := r.requests.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	requests := []*domain.FriendRequest{}
+	if err := cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func (r *contactRepository) ResolveRequest(ctx context.Context, id, status string, handledAt time.Time) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	result, err := r.requests.UpdateOne(ctx,
+		bson.M{"_id": objectID, "status": domain.FriendRequestPending},
+		bson.M{"$set": bson.M{"status": status, "handledAt": handledAt}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *contactRepository) AddContact(ctx context.Context, userID, contactID string, createdAt time.Time) error {
+	for _, pair := range [][2]string{{userID, contactID}, {contactID, userID}} {
+		filter := bson.M{"userId": pair[0], "contactId": pair[1]}
+		update := bson.M{"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "createdAt": createdAt}}
+		if _, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *contactRepository) RemoveContact(ctx context.Context, userID, contactID string) (bool, error) {
+	result, err := r.collection.DeleteMany(ctx, bson.M{"$or": bson.A{
+		bson.M{"userId": userID, "contactId": contactID},
+		bson.M{"userId": contactID, "contactId": userID},
+	}})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (r *contactRepository) IsContact(ctx context.Context, userID, contactID string) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"userId": userID, "contactId": contactID}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *contactRepository) ListContactIDs(ctx context.Context, userID string) ([]string, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"userId": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var contacts []domain.Contact
+	if err := cursor.All(ctx, &contacts); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(contacts))
+	for _, contact := range contacts {
+		ids = append(ids, contact.ContactID)
+	}
+	return ids, nil
+}
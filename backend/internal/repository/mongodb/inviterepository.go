@@ -0,0 +1,117 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type inviteRepository struct {
+	collection *mongo.Collection
+}
+
+// NewInviteRepository 创建一个新的 InviteRepository 实例
+func NewInviteRepository(db *mongo.Database) *inviteRepository {
+	return &inviteRepository{
+		collection: db.Collection("chat_invites"),
+	}
+}
+
+// EnsureIndexes 创建邀请链接集合需要的索引
+func (r *inviteRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "chatId", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+	})
+	return err
+}
+
+func (r *inviteRepository) Create(ctx context.Context, invite *domain.ChatInvite) error {
+	if invite.ID.IsZero() {
+		invite.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, invite)
+	return err
+}
+
+func (r *inviteRepository) GetByToken(ctx context.Context, token string) (*domain.ChatInvite, error) {
+	var invite domain.ChatInvite
+	if err := r.collection.FindOne(ctx, bson.M{"token": token}).Decode(&invite); err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+func (r *inviteRepository) ListActive(ctx context.Context, chatID string, now time.Time) ([]*domain.ChatInvite, error) {
+	filter := activeFilter(now)
+	filter["chatId"] = chatID
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	invites := []*domain.ChatInvite{}
+	if err := cursor.All(ctx, &invites); err != nil {
+		return nil, err
+	}
+	return invites, nil
+}
+
+func (r *inviteRepository) Revoke(ctx context.Context, chatID, inviteID string, revokedAt time.Time) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(inviteID)
+	if err != nil {
+		return false, err
+	}
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": objectID, "chatId": chatID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": revokedAt}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *inviteRepository) Use(ctx context.Context, inviteID string, now time.Time) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(inviteID)
+	if err != nil {
+		return false, err
+	}
+	filter := activeFilter(now)
+	filter["_id"] = objectID
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"useCount": 1}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// activeFilter 匹配未撤销、未过期且未用完的邀请链接，与 domain.ChatInvite.Active 一致
+func activeFilter(now time.Time) bson.M {
+	return bson.M{
+		"revokedAt": bson.M{"$exists": false},
+		"$and": bson.A{
+			bson.M{"$or": bson.A{
+				bson.M{"expiresAt": bson.M{"$exists": false}},
+				bson.M{"expiresAt": bson.M{"$gt": now}},
+			}},
+			bson.M{"$or": bson.A{
+				bson.M{"maxUses": 0},
+				bson.M{"$expr": bson.M{"$lt": bson.A{"$useCount", "$maxUses"}}},
+			}},
+		},
+	}
+}
@@ -0,0 +1,104 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type joinRequestRepository struct {
+	collection *mongo.Collection
+}
+
+// NewJoinRequestRepository 创建一个新的 JoinRequestRepository 实例
+func NewJoinRequestRepository(db *mongo.Database) *joinRequestRepository {
+	return &joinRequestRepository{
+		collection: db.Collection("join_requests"),
+	}
+}
+
+// EnsureIndexes 创建入群申请集合需要的索引
+func (r *joinRequestRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// 同一用户在同一聊天只能有一个待处理的申请
+			Keys: bson.D{{Key: "chatId", Value: 1}, {Key: "userId", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": domain.JoinRequestPending}),
+		},
+		{
+			Keys: bson.D{{Key: "chatId", Value: 1}, {Key: "status", Value: 1}, {Key: "createdAt", Value: 1}},
+		},
+	})
+	return err
+}
+
+func (r *joinRequestRepository) Create(ctx context.Context, request *domain.JoinRequest) (*domain.JoinRequest, bool, error) {
+	if request.ID.IsZero() {
+		request.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, request)
+	if err == nil {
+		return request, true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, false, err
+	}
+
+	var existing domain.JoinRequest
+	filter := bson.M{"chatId": request.ChatID, "userId": request.UserID, "status": domain.JoinRequestPending}
+	if err := r.collection.FindOne(ctx, filter).Decode(&existing); err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+func (r *joinRequestRepository) GetByID(ctx context.Context, id string) (*domain.JoinRequest, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var request domain.JoinRequest
+	if err := r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *joinRequestRepository) ListPending(ctx context.Context, chatID string) ([]*domain.JoinRequest, error) {
+	filter := bson.M{"chatId": chatID, "status": domain.JoinRequestPending}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	requests := []*domain.JoinRequest{}
+	if err := cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func (r *joinRequestRepository) Resolve(ctx context.Context, id, status, handledBy string, handledAt time.Time) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": objectID, "status": domain.JoinRequestPending},
+		bson.M{"$set": bson.M{"status": status, "handledBy": handledBy, "handledAt": handledAt}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
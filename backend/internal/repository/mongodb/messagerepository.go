@@ -2,6 +2,8 @@ package mongodb
 
 import (
 	"context"
+	"time"
+
 	"github.com/baoerzuikeai/Imsystem/internal/domain"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -19,6 +21,31 @@ func NewMessageRepository(db *mongo.Database) *messageRepository {
 	}
 }
 
+// EnsureIndexes 创建消息集合需要的索引
+func (r *messageRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// 同一发送者的 clientMsgId 唯一，客户端重发时不会产生重复消息
+			Keys: bson.D{{Key: "senderId", Value: 1}, {Key: "clientMsgId", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"clientMsgId": bson.M{"$exists": true}}),
+		},
+		{
+			// 历史消息游标分页，_id 用于同一时间戳的消息排序
+			Keys: bson.D{{Key: "chatId", Value: 1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}},
+		},
+		{
+			// 聊天内序号唯一，用于断线重连后补发
+			Keys: bson.D{{Key: "chatId", Value: 1}, {Key: "seq", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"seq": bson.M{"$exists": true}}),
+		},
+	})
+	return err
+}
+
 func (r *messageRepository) Create(ctx context.Context, message *domain.Message) error {
 	if message.ID.IsZero() {
 		message.ID = primitive.NewObjectID()
@@ -46,6 +73,74 @@ func (r *messageRepository) GetByChatID(ctx context.Context, chatID string, limi
 	return messages, nil
 }
 
+func (r *messageRepository) GetAfterSeq(ctx context.Context, chatID string, afterSeq int64, limit int) ([]*domain.Message, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "seq", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, bson.M{"chatId": chatID, "seq": bson.M{"$gt": afterSeq}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []*domain.Message
+	if err = cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *messageRepository) GetBefore(ctx context.Context, chatID string, anchor *domain.Message, limit int) ([]*domain.Message, error) {
+	filter := bson.M{"chatId": chatID}
+	if anchor != nil {
+		filter["$or"] = []bson.M{
+			{"createdAt": bson.M{"$lt": anchor.CreatedAt}},
+			{"createdAt": anchor.CreatedAt, "_id": bson.M{"$lt": anchor.ID}},
+		}
+	}
+	return r.findPage(ctx, filter, -1, limit)
+}
+
+func (r *messageRepository) GetAfter(ctx context.Context, chatID string, anchor *domain.Message, limit int) ([]*domain.Message, error) {
+	filter := bson.M{
+		"chatId": chatID,
+		"$or": []bson.M{
+			{"createdAt": bson.M{"$gt": anchor.CreatedAt}},
+			{"createdAt": anchor.CreatedAt, "_id": bson.M{"$gt": anchor.ID}},
+		},
+	}
+	return r.findPage(ctx, filter, 1, limit)
+}
+
+// findPage 按 (createdAt, _id) 排序查询，direction 为 1 正序，-1 倒序
+func (r *messageRepository) findPage(ctx context.Context, filter bson.M, direction int, limit int) ([]*domain.Message, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []*domain.Message
+	if err = cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *messageRepository) GetBySeq(ctx context.Context, chatID string, seq int64) (*domain.Message, error) {
+	var message domain.Message
+	err := r.collection.FindOne(ctx, bson.M{"chatId": chatID, "seq": seq}).Decode(&message)
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
 func (r *messageRepository) GetByID(ctx context.Context, id string) (*domain.Message, error) {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -60,6 +155,36 @@ func (r *messageRepository) GetByID(ctx context.Context, id string) (*domain.Mes
 	return &message, nil
 }
 
+func (r *messageRepository) GetByIDs(ctx context.Context, ids []string) ([]*domain.Message, error) {
+	objectIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if objectID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objectIDs = append(objectIDs, objectID)
+		}
+	}
+
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": objectIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	messages := []*domain.Message{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *messageRepository) GetByClientMsgID(ctx context.Context, senderID, clientMsgID string) (*domain.Message, error) {
+	var message domain.Message
+	err := r.collection.FindOne(ctx, bson.M{"senderId": senderID, "clientMsgId": clientMsgID}).Decode(&message)
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
 func (r *messageRepository) Delete(ctx context.Context, id string) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -69,3 +194,234 @@ func (r *messageRepository) Delete(ctx context.Context, id string) error {
 	_, err = r.collection.DeleteOne(ctx, bson.M{"_id": objectID})
 	return err
 }
+
+func (r *messageRepository) UpdateContent(ctx context.Context, id string, content domain.MessageContent, mentions []string, mentionScope string, previous domain.MessageEdit) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	set := bson.M{
+		"content":  content,
+		"editedAt": previous.EditedAt,
+	}
+	unset := bson.M{}
+	if len(mentions) > 0 {
+		set["mentions"] = mentions
+	} else {
+		unset["mentions"] = ""
+	}
+	if mentionScope != "" {
+		set["mentionScope"] = mentionScope
+	} else {
+		unset["mentionScope"] = ""
+	}
+	update := bson.M{
+		"$set":  set,
+		"$push": bson.M{"editHistory": previous},
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *messageRepository) SoftDelete(ctx context.Context, id string, deletedBy string, deletedAt time.Time) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	// 只处理尚未删除的消息，避免重复删除覆盖原删除人
+	filter := bson.M{"_id": objectID, "deletedAt": bson.M{"$exists": false}}
+	update := bson.M{
+		"$set": bson.M{
+			"content":   domain.MessageContent{},
+			"deletedAt": deletedAt,
+			"deletedBy": deletedBy,
+		},
+		"$unset": bson.M{"editHistory": ""},
+	}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *messageRepository) GetThreadReplies(ctx context.Context, rootID string, limit, offset int) ([]*domain.Message, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, bson.M{"threadRootId": rootID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []*domain.Message
+	if err = cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *messageRepository) IncrementReplyCount(ctx context.Context, rootID string, repliedAt time.Time) error {
+	objectID, err := primitive.ObjectIDFromHex(rootID)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$inc": bson.M{"replyCount": 1},
+		"$max": bson.M{"lastReplyAt": repliedAt},
+	}
+	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	return err
+}
+
+func (r *messageRepository) AddReaction(ctx context.Context, id, emoji, userID string) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	// 并发时另一个请求可能刚好创建了该表情的聚合项，所以最多尝试两轮
+	for i := 0; i < 2; i++ {
+		// 表情已存在且用户尚未回应：加入用户列表
+		filter := bson.M{
+			"_id": objectID,
+			"$and": []bson.M{
+				{"reactions": bson.M{"$elemMatch": bson.M{"emoji": emoji}}},
+				{"reactions": bson.M{"$not": bson.M{"$elemMatch": bson.M{"emoji": emoji, "userIds": userID}}}},
+			},
+		}
+		update := bson.M{
+			"$push": bson.M{"reactions.$[r].userIds": userID},
+			"$inc":  bson.M{"reactions.$[r].count": 1},
+		}
+		opts := options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"r.emoji": emoji}},
+		})
+		result, err := r.collection.UpdateOne(ctx, filter, update, opts)
+		if err != nil {
+			return false, err
+		}
+		if result.ModifiedCount > 0 {
+			return true, nil
+		}
+
+		// 表情不存在：新建聚合项
+		filter = bson.M{"_id": objectID, "reactions.emoji": bson.M{"$ne": emoji}}
+		update = bson.M{"$push": bson.M{"reactions": domain.Reaction{
+			Emoji:   emoji,
+			UserIDs: []string{userID},
+			Count:   1,
+		}}}
+		result, err = r.collection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return false, err
+		}
+		if result.ModifiedCount > 0 {
+			return true, nil
+		}
+
+		// 用户已经回应过该表情
+		count, err := r.collection.CountDocuments(ctx, bson.M{
+			"_id":       objectID,
+			"reactions": bson.M{"$elemMatch": bson.M{"emoji": emoji, "userIds": userID}},
+		})
+		if err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return false, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *messageRepository) RemoveReaction(ctx context.Context, id, emoji, userID string) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.M{
+		"_id":       objectID,
+		"reactions": bson.M{"$elemMatch": bson.M{"emoji": emoji, "userIds": userID}},
+	}
+	update := bson.M{
+		"$pull": bson.M{"reactions.$.userIds": userID},
+		"$inc":  bson.M{"reactions.$.count": -1},
+	}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	if result.ModifiedCount == 0 {
+		return false, nil
+	}
+
+	// 清理已经没有用户的表情
+	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{
+		"$pull": bson.M{"reactions": bson.M{"count": bson.M{"$lte": 0}}},
+	})
+	return true, err
+}
+
+func (r *messageRepository) CountUnread(ctx context.Context, userID string, since map[string]time.Time) (map[string]domain.UnreadCounts, error) {
+	counts := make(map[string]domain.UnreadCounts, len(since))
+	if len(since) == 0 {
+		return counts, nil
+	}
+
+	chatFilters := make([]bson.M, 0, len(since))
+	for chatID, t := range since {
+		chatFilters = append(chatFilters, bson.M{"chatId": chatID, "createdAt": bson.M{"$gt": t}})
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"$or":       chatFilters,
+			"senderId":  bson.M{"$ne": userID},
+			"deletedAt": bson.M{"$exists": false},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$chatId",
+			"count": bson.M{"$sum": 1},
+			"mentions": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$in": bson.A{userID, bson.M{"$ifNull": bson.A{"$mentions", bson.A{}}}}}, 1, 0,
+			}}},
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		ChatID              string `bson:"_id"`
+		domain.UnreadCounts `bson:",inline"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		counts[result.ChatID] = result.UnreadCounts
+	}
+	return counts, nil
+}
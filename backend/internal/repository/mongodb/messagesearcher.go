@@ -0,0 +1,156 @@
+package mongodb
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// messageSearcher 基于 messages 集合文本索引的消息检索。
+// 文本索引按空格和标点分词，中日韩文本整句会被当作一个词，只能匹配完整的句子，
+// 所以关键字包含中日韩字符时改用不区分大小写的正则匹配，这种查询无法使用文本索引，
+// 只靠 chatId 索引缩小扫描范围
+type messageSearcher struct {
+	collection *mongo.Collection
+}
+
+func NewMessageSearcher(db *mongo.Database) *messageSearcher {
+	return &messageSearcher{
+		collection: db.Collection("messages"),
+	}
+}
+
+// EnsureIndexes 创建文本索引，每个集合只能有一个文本索引
+func (r *messageSearcher) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "content.text", Value: "text"}, {Key: "content.code.content", Value: "text"}},
+		Options: options.Index().
+			SetName("message_text").
+			SetDefaultLanguage("none"), // 不做词干提取和停用词过滤，中英文和代码都按原词匹配
+	})
+	return err
+}
+
+func (r *messageSearcher) Search(ctx context.Context, query domain.MessageSearchQuery) ([]*domain.Message, error) {
+	filter := bson.M{
+		"chatId":    bson.M{"$in": query.ChatIDs},
+		"deletedAt": bson.M{"$exists": false},
+	}
+	var conditions bson.A
+	if containsCJK(query.Keyword) {
+		conditions = append(conditions, keywordConditions(query.Keyword)...)
+	} else {
+		filter["$text"] = bson.M{"$search": query.Keyword}
+	}
+	if len(query.Types) > 0 {
+		filter["type"] = bson.M{"$in": query.Types}
+	}
+	if query.SenderID != "" {
+		filter["senderId"] = query.SenderID
+	}
+	createdAt := bson.M{}
+	if query.From != nil {
+		createdAt["$gte"] = *query.From
+	}
+	if query.To != nil {
+		createdAt["$lt"] = *query.To
+	}
+	if len(createdAt) > 0 {
+		filter["createdAt"] = createdAt
+	}
+	if query.BeforeID != "" {
+		beforeID, err := primitive.ObjectIDFromHex(query.BeforeID)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{"createdAt": bson.M{"$lt": query.BeforeAt}},
+			bson.M{"createdAt": query.BeforeAt, "_id": bson.M{"$lt": beforeID}},
+		}})
+	}
+	if len(conditions) > 0 {
+		filter["$and"] = conditions
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(query.Limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	messages := []*domain.Message{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// containsCJK 判断关键字是否包含中日韩字符
+func containsCJK(keyword string) bool {
+	for _, r := range keyword {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			return true
+		}
+	}
+	return false
+}
+
+// keywordConditions 按文本索引的语法把关键字转换为正则条件：
+// 双引号包围的短语必须出现，普通词出现任意一个即可，以 - 开头的词不能出现
+func keywordConditions(keyword string) bson.A {
+	var conditions bson.A
+	var words, excluded bson.A
+	for i, part := range strings.Split(keyword, `"`) {
+		if i%2 == 1 {
+			if phrase := strings.TrimSpace(part); phrase != "" {
+				conditions = append(conditions, bson.M{"$or": contentRegexes(phrase)})
+			}
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			if strings.HasPrefix(word, "-") {
+				if word = strings.TrimPrefix(word, "-"); word != "" {
+					excluded = append(excluded, contentRegexes(word)...)
+				}
+				continue
+			}
+			words = append(words, contentRegexes(word)...)
+		}
+	}
+	if len(words) > 0 {
+		conditions = append(conditions, bson.M{"$or": words})
+	}
+	if len(excluded) > 0 {
+		conditions = append(conditions, bson.M{"$nor": excluded})
+	}
+	return conditions
+}
+
+// contentRegexes 匹配文本或代码内容中出现 term，不区分大小写
+func contentRegexes(term string) bson.A {
+	pattern := primitive.Regex{Pattern: regexp.QuoteMeta(term), Options: "i"}
+	return bson.A{
+		bson.M{"content.text": pattern},
+		bson.M{"content.code.content": pattern},
+	}
+}
+
+// Index 文本索引由 MongoDB 在写入时维护
+func (r *messageSearcher) Index(ctx context.Context, message *domain.Message) error {
+	return nil
+}
+
+// Remove 撤回的消息内容已清空且带有 deletedAt，检索时会被过滤
+func (r *messageSearcher) Remove(ctx context.Context, messageID string) error {
+	return nil
+}
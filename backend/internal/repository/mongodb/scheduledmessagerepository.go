@@ -0,0 +1,202 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type scheduledMessageRepository struct {
+	collection *mongo.Collection
+}
+
+// NewScheduledMessageRepository 创建一个新的 ScheduledMessageRepository 实例
+func NewScheduledMessageRepository(db *mongo.Database) *scheduledMessageRepository {
+	return &scheduledMessageRepository{
+		collection: db.Collection("scheduled_messages"),
+	}
+}
+
+// EnsureIndexes 创建定时消息集合需要的索引
+func (r *scheduledMessageRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// 投递任务按状态和投递时间查找到期消息
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "sendAt", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "senderId", Value: 1}, {Key: "status", Value: 1}, {Key: "sendAt", Value: 1}},
+		},
+	})
+	return err
+}
+
+func (r *scheduledMessageRepository) Create(ctx context.Context, message *domain.ScheduledMessage) error {
+	if message.ID.IsZero() {
+		message.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, message)
+	return err
+}
+
+func (r *scheduledMessageRepository) GetByID(ctx context.Context, id string) (*domain.ScheduledMessage, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var message domain.ScheduledMessage
+	if err := r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (r *scheduledMessageRepository) ListPending(ctx context.Context, senderID, chatID string) ([]*domain.ScheduledMessage, error) {
+	filter := bson.M{"senderId": senderID, "status": domain.ScheduledPending}
+	if chatID != "" {
+		filter["chatId"] = chatID
+	}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "sendAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	messages := []*domain.ScheduledMessage{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *scheduledMessageRepository) UpdatePending(ctx context.Context, id, senderID string, content *domain.MessageContent, sendAt *time.Time, now time.Time) (*domain.ScheduledMessage, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	set := bson.M{"updatedAt": now}
+	if content != nil {
+		set["content"] = *content
+	}
+	if sendAt != nil {
+		set["sendAt"] = *sendAt
+	}
+	update := bson.M{"$set": set}
+	if sendAt != nil {
+		// 重新安排投递时间后不再等待上一次失败的重试间隔
+		update["$unset"] = bson.M{"nextAttemptAt": ""}
+	}
+	filter := bson.M{"_id": objectID, "senderId": senderID, "status": domain.ScheduledPending}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var message domain.ScheduledMessage
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (r *scheduledMessageRepository) Cancel(ctx context.Context, id, senderID string, now time.Time) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.M{"_id": objectID, "senderId": senderID, "status": domain.ScheduledPending}
+	update := bson.M{"$set": bson.M{"status": domain.ScheduledCanceled, "updatedAt": now}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *scheduledMessageRepository) ClaimDue(ctx context.Context, now time.Time) (*domain.ScheduledMessage, error) {
+	filter := bson.M{
+		"status": domain.ScheduledPending,
+		"sendAt": bson.M{"$lte": now},
+		"$or": bson.A{
+			bson.M{"nextAttemptAt": bson.M{"$exists": false}},
+			bson.M{"nextAttemptAt": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"status": domain.ScheduledSending, "claimedAt": now}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "sendAt", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var message domain.ScheduledMessage
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (r *scheduledMessageRepository) ReleaseStale(ctx context.Context, before time.Time) (int64, error) {
+	filter := bson.M{"status": domain.ScheduledSending, "claimedAt": bson.M{"$lt": before}}
+	update := bson.M{
+		"$set":   bson.M{"status": domain.ScheduledPending},
+		"$unset": bson.M{"claimedAt": ""},
+	}
+	result, err := r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *scheduledMessageRepository) MarkSent(ctx context.Context, id, messageID string, sentAt time.Time) error {
+	return r.finish(ctx, id, bson.M{
+		"status":    domain.ScheduledSent,
+		"messageId": messageID,
+		"sentAt":    sentAt,
+		"updatedAt": sentAt,
+	})
+}
+
+func (r *scheduledMessageRepository) MarkFailed(ctx context.Context, id, reason string, now time.Time) error {
+	return r.finish(ctx, id, bson.M{
+		"status":    domain.ScheduledFailed,
+		"error":     reason,
+		"updatedAt": now,
+	})
+}
+
+func (r *scheduledMessageRepository) Retry(ctx context.Context, id, reason string, nextAttempt, now time.Time) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"status":        domain.ScheduledPending,
+			"error":         reason,
+			"nextAttemptAt": nextAttempt,
+			"updatedAt":     now,
+		},
+		"$inc":   bson.M{"attempts": 1},
+		"$unset": bson.M{"claimedAt": ""},
+	}
+	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": objectID, "status": domain.ScheduledSending}, update)
+	return err
+}
+
+// finish 结束一条投递中的消息
+func (r *scheduledMessageRepository) finish(ctx context.Context, id string, set bson.M) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{"$set": set, "$unset": bson.M{"claimedAt": ""}}
+	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": objectID, "status": domain.ScheduledSending}, update)
+	return err
+}
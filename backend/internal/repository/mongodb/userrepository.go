@@ -2,6 +2,7 @@ package mongodb
 
 import (
 	"context"
+	"time"
 
 	"github.com/baoerzuikeai/Imsystem/internal/domain"
 	"go.mongodb.org/mongo-driver/bson"
@@ -39,6 +40,27 @@ func (r *userRepository) GetByID(ctx context.Context, id string) (*domain.User,
 	return &user, nil
 }
 
+func (r *userRepository) GetByIDs(ctx context.Context, ids []string) ([]*domain.User, error) {
+	objectIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if objectID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objectIDs = append(objectIDs, objectID)
+		}
+	}
+
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": objectIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []*domain.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 // 在 userrepository.go 中添加以下方法
 
 func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
@@ -53,6 +75,20 @@ func (r *userRepository) GetByUsername(ctx context.Context, username string) (*d
 	return &user, err
 }
 
+func (r *userRepository) GetByUsernames(ctx context.Context, usernames []string) ([]*domain.User, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"username": bson.M{"$in": usernames}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []*domain.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 func (r *userRepository) Update(ctx context.Context, user *domain.User) error {
 	_, err := r.collection.UpdateOne(
 		ctx,
@@ -90,4 +126,30 @@ func (r *userRepository) SearchUsers(ctx context.Context, keyword string) ([]*do
         return nil, err
     }
     return users, nil
-}
\ No newline at end of file
+}
+
+func (r *userRepository) UpdateStatus(ctx context.Context, id string, status domain.UserStatus) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$set": bson.M{"status": status}})
+	return err
+}
+
+func (r *userRepository) ResetOnlineStatus(ctx context.Context) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"status.online": true},
+		bson.M{"$set": bson.M{"status.online": false}},
+	)
+	return err
+}
+
+func (r *userRepository) UpdatePrivacy(ctx context.Context, id string, privacy domain.Privacy) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$set": bson.M{"privacy": privacy, "updatedAt": time.Now()}})
+	return err
+}
@@ -16,13 +16,16 @@ type AuthService struct {
 	userRepo  interfaces.UserRepository
 	jwtSecret string
 	jwtExpiry int64
+
+	contactService ContactService
 }
 
-func NewAuthService(userRepo interfaces.UserRepository, jwtSecret string, jwtExpiry int64) *AuthService {
+func NewAuthService(userRepo interfaces.UserRepository, jwtSecret string, jwtExpiry int64, contactService ContactService) *AuthService {
 	return &AuthService{
-		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
-		jwtExpiry: jwtExpiry,
+		userRepo:       userRepo,
+		jwtSecret:      jwtSecret,
+		jwtExpiry:      jwtExpiry,
+		contactService: contactService,
 	}
 }
 
@@ -52,7 +55,7 @@ func (s *AuthService) Register(ctx context.Context, username, email, password, n
 			Nickname: nickname,
 		},
 		Status: domain.UserStatus{
-			Online:   true,
+			Online:   false, // 在线状态由 WebSocket 连接维护
 			LastSeen: time.Now(),
 		},
 		CreatedAt: time.Now(),
@@ -82,15 +85,6 @@ func (s *AuthService) Login(ctx context.Context, email, password string) (*domai
 		return nil, "", err
 	}
 
-	// 更新用户状态
-	user.Status.Online = true
-	user.Status.LastSeen = time.Now()
-	user.UpdatedAt = time.Now()
-
-	if err := s.userRepo.Update(ctx, user); err != nil {
-		return nil, "", err
-	}
-
 	return user, token, nil
 }
 
@@ -103,6 +97,11 @@ func (s *AuthService) GetUserByID(ctx context.Context, userID string) (*domain.U
 	return user, nil
 }
 
-func (s *AuthService) SearchUsers(ctx context.Context, keyword string) ([]*domain.User, error) {
-    return s.userRepo.SearchUsers(ctx, keyword)
-}
\ No newline at end of file
+// SearchUsers 搜索用户，结果中不包括屏蔽了 viewerID 的用户
+func (s *AuthService) SearchUsers(ctx context.Context, keyword, viewerID string) ([]*domain.User, error) {
+	users, err := s.userRepo.SearchUsers(ctx, keyword)
+	if err != nil {
+		return nil, err
+	}
+	return s.contactService.FilterBlockers(ctx, viewerID, users)
+}
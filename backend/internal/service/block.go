@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+)
+
+// blockCacheTTL 屏蔽列表缓存的有效期，屏蔽或取消屏蔽时会主动失效
+const blockCacheTTL = 5 * time.Minute
+
+type blockedEntry struct {
+	blockedIDs map[string]bool
+	expiresAt  time.Time
+}
+
+func (s *contactService) BlockUser(ctx context.Context, userID, targetID string) error {
+	if userID == targetID {
+		return ErrInvalidBlock
+	}
+	if _, err := s.getUser(ctx, targetID); err != nil {
+		return err
+	}
+	defer s.invalidateBlocked(userID)
+	return s.blockRepo.Block(ctx, userID, targetID, time.Now())
+}
+
+func (s *contactService) UnblockUser(ctx context.Context, userID, targetID string) error {
+	defer s.invalidateBlocked(userID)
+	unblocked, err := s.blockRepo.Unblock(ctx, userID, targetID)
+	if err != nil {
+		return err
+	}
+	if !unblocked {
+		return ErrNotBlocked
+	}
+	return nil
+}
+
+func (s *contactService) ListBlocked(ctx context.Context, userID string) ([]*domain.User, error) {
+	blockedIDs, err := s.blockRepo.ListBlockedIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.getUsers(ctx, blockedIDs)
+}
+
+func (s *contactService) IsBlocked(ctx context.Context, userID, targetID string) (bool, error) {
+	entry, err := s.loadBlocked(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return entry.blockedIDs[targetID], nil
+}
+
+func (s *contactService) CheckCanReach(ctx context.Context, chatID, userID string) error {
+	peerID, err := s.membershipService.GetPrivatePeer(ctx, chatID, userID)
+	if err != nil || peerID == "" {
+		return err
+	}
+	return s.checkNotBlocked(ctx, peerID, userID)
+}
+
+func (s *contactService) FilterBlockers(ctx context.Context, viewerID string, users []*domain.User) ([]*domain.User, error) {
+	blockerIDs, err := s.blockRepo.ListBlockerIDs(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(blockerIDs) == 0 {
+		return users, nil
+	}
+
+	blockers := make(map[string]bool, len(blockerIDs))
+	for _, blockerID := range blockerIDs {
+		blockers[blockerID] = true
+	}
+	visible := make([]*domain.User, 0, len(users))
+	for _, user := range users {
+		if !blockers[user.ID.Hex()] {
+			visible = append(visible, user)
+		}
+	}
+	return visible, nil
+}
+
+func (s *contactService) invalidateBlocked(userID string) {
+	s.blockedMutex.Lock()
+	delete(s.blockedCache, userID)
+	s.blockedVersions[userID]++
+	s.blockedMutex.Unlock()
+}
+
+// loadBlocked 优先从缓存读取用户屏蔽的用户，缓存缺失或过期时查询数据库
+func (s *contactService) loadBlocked(ctx context.Context, userID string) (*blockedEntry, error) {
+	s.blockedMutex.RLock()
+	entry, ok := s.blockedCache[userID]
+	version := s.blockedVersions[userID]
+	s.blockedMutex.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry, nil
+	}
+
+	blockedIDs, err := s.blockRepo.ListBlockedIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	entry = &blockedEntry{
+		blockedIDs: make(map[string]bool, len(blockedIDs)),
+		expiresAt:  time.Now().Add(blockCacheTTL),
+	}
+	for _, blockedID := range blockedIDs {
+		entry.blockedIDs[blockedID] = true
+	}
+
+	// 查询期间屏蔽列表发生变化时，读到的结果只用于本次调用
+	s.blockedMutex.Lock()
+	if s.blockedVersions[userID] == version {
+		s.blockedCache[userID] = entry
+	}
+	s.blockedMutex.Unlock()
+	return entry, nil
+}
@@ -2,11 +2,13 @@ package service
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/baoerzuikeai/Imsystem/internal/domain"
 	"github.com/baoerzuikeai/Imsystem/internal/repository/interfaces"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type ChatService interface {
@@ -14,55 +16,82 @@ type ChatService interface {
 	GetPrivateChatFriends(ctx context.Context, currentUserID string) ([]*domain.User, error)
 	GetPrivateChatByUserID(ctx context.Context, userID string) ([]*domain.Chat, error)
 	GetGroupChatByUserID(ctx context.Context, userID string) ([]*domain.Chat, error)
-	CreatePrivateChat(ctx context.Context, userID1, userID2 string) (*domain.Chat, error)
+	// 获取或创建私聊，新创建时返回 true
+	CreatePrivateChat(ctx context.Context, userID1, userID2 string) (*domain.Chat, bool, error)
 	GetAllChatsByUserID(ctx context.Context, userID string) ([]*domain.Chat, error)
+	// 按最后活跃时间倒序分页获取聊天列表，包含最后一条消息摘要和未读数
+	ListChats(ctx context.Context, userID string, opts ChatListOptions) (*ChatPage, error)
+	// 更新当前用户对聊天的置顶、免打扰和归档设置，返回更新后的成员信息
+	UpdatePreferences(ctx context.Context, chatID, userID string, update domain.MemberPreferencesUpdate) (*domain.ChatMember, error)
 	GetChatMembers(ctx context.Context, chatID string) ([]*domain.User, error)
 	CreateGroupChat(ctx context.Context, ownerID string, title string,  memberIDs []string) (*domain.Chat, error)
+
+	// 群成员管理，没有产生变化时返回 nil；添加成员中途出错时同时返回已生效的变化和错误
+	AddMembers(ctx context.Context, chatID, operatorID string, userIDs []string) (*MemberChange, error)
+	RemoveMember(ctx context.Context, chatID, operatorID, userID string) (*MemberChange, error)
+	LeaveChat(ctx context.Context, chatID, userID string) (*MemberChange, error)
+	SetMemberRole(ctx context.Context, chatID, operatorID, userID, role string) (*MemberChange, error)
+	TransferOwnership(ctx context.Context, chatID, operatorID, newOwnerID string) (*MemberChange, error)
+
+	// 修改群名称、头像、简介和公告，需要群主或管理员权限
+	UpdateSettings(ctx context.Context, chatID, operatorID string, request ChatSettingsRequest) (*SettingsChange, error)
+
+	// 创建、列出和撤销邀请链接，需要群主或管理员权限
+	CreateInvite(ctx context.Context, chatID, operatorID string, opts InviteOptions) (*domain.ChatInvite, error)
+	ListInvites(ctx context.Context, chatID, operatorID string) ([]*domain.ChatInvite, error)
+	RevokeInvite(ctx context.Context, chatID, operatorID, inviteID string) error
+	// 通过邀请链接加入群聊，群聊或链接需要审批时创建入群申请
+	JoinByInvite(ctx context.Context, token, userID string) (*JoinResult, error)
+
+	// 直接申请加入群聊，群聊不是公开群聊或需要审批时创建入群申请
+	JoinChat(ctx context.Context, chatID, userID, message string) (*JoinResult, error)
+	// 获取、通过和拒绝入群申请，需要群主或管理员权限
+	ListJoinRequests(ctx context.Context, chatID, operatorID string) ([]*domain.JoinRequest, error)
+	ApproveJoinRequest(ctx context.Context, chatID, operatorID, requestID string) (*JoinDecision, error)
+	RejectJoinRequest(ctx context.Context, chatID, operatorID, requestID string) (*JoinDecision, error)
+
+	// 置顶和取消置顶消息，群聊需要群主或管理员权限
+	PinMessage(ctx context.Context, chatID, operatorID, messageID string) (*PinChange, error)
+	UnpinMessage(ctx context.Context, chatID, operatorID, messageID string) (*PinChange, error)
+	// 按置顶时间倒序获取置顶消息
+	ListPins(ctx context.Context, chatID string) ([]*domain.PinnedMessage, error)
+
+	// 将来源聊天中的消息转发到其他聊天，返回按目标聊天依次生成的新消息
+	ForwardMessages(ctx context.Context, userID, sourceChatID string, messageIDs, targetChatIDs []string) ([]*domain.Message, error)
 }
 
 type chatService struct {
-	chatRepo interfaces.ChatRepository
+	chatRepo          interfaces.ChatRepository
+	messageRepo       interfaces.MessageRepository
+	userRepo          interfaces.UserRepository
+	fileRepo          interfaces.FileRepository
+	inviteRepo        interfaces.InviteRepository
+	joinRequestRepo   interfaces.JoinRequestRepository
+	messageService    MessageService
+	membershipService MembershipService
+	contactService    ContactService
 }
 
-func NewChatService(chatRepo interfaces.ChatRepository) ChatService {
+func NewChatService(chatRepo interfaces.ChatRepository, messageRepo interfaces.MessageRepository, userRepo interfaces.UserRepository,
+	fileRepo interfaces.FileRepository, inviteRepo interfaces.InviteRepository,
+	joinRequestRepo interfaces.JoinRequestRepository, messageService MessageService, membershipService MembershipService,
+	contactService ContactService) ChatService {
 	return &chatService{
-		chatRepo: chatRepo,
+		chatRepo:          chatRepo,
+		messageRepo:       messageRepo,
+		userRepo:          userRepo,
+		fileRepo:          fileRepo,
+		inviteRepo:        inviteRepo,
+		joinRequestRepo:   joinRequestRepo,
+		messageService:    messageService,
+		membershipService: membershipService,
+		contactService:    contactService,
 	}
 }
 
-// GetPrivateChatFriends 根据当前用户ID查询参与的 private 聊天，然后返回其它聊天成员作为好友信息
+// GetPrivateChatFriends 返回当前用户的联系人
 func (s *chatService) GetPrivateChatFriends(ctx context.Context, currentUserID string) ([]*domain.User, error) {
-	// 查询当前用户参与的私聊
-	chats, err := s.chatRepo.GetChatsByUserAndType(ctx, currentUserID, "private")
-	if err != nil {
-		return nil, err
-	}
-	userObjID, err := primitive.ObjectIDFromHex(currentUserID)
-	if err != nil {
-		return nil, err
-	}
-	friendMap := make(map[string]*domain.User)
-	for _, chat := range chats {
-		// 获取聊天成员
-		strChatId := chat.ID.Hex()
-		users, err := s.chatRepo.GetChatMembers(ctx, strChatId)
-		if err != nil {
-			// 出错可选择忽略该聊天
-			continue
-		}
-		// 遍历聊天成员，排除自己
-		for _, u := range users {
-			if u.ID != userObjID {
-				friendMap[u.ID.Hex()] = u
-			}
-		}
-	}
-
-	var friends []*domain.User
-	for _, friend := range friendMap {
-		friends = append(friends, friend)
-	}
-	return friends, nil
+	return s.contactService.ListContacts(ctx, currentUserID)
 }
 
 func (s *chatService) GetPrivateChatByUserID(ctx context.Context, userID string) ([]*domain.Chat, error) {
@@ -73,31 +102,81 @@ func (s *chatService) GetGroupChatByUserID(ctx context.Context, userID string) (
 	return s.chatRepo.GetChatsByUserAndType(ctx, userID, "group")
 }
 
-func (s *chatService) CreatePrivateChat(ctx context.Context, userID1, userID2 string) (*domain.Chat, error) {
+// CreatePrivateChat 获取或创建与目标用户的私聊，新创建时返回 true
+func (s *chatService) CreatePrivateChat(ctx context.Context, userID1, userID2 string) (*domain.Chat, bool, error) {
+	pairKey := domain.PrivatePairKey(userID1, userID2)
+	existing, err := s.chatRepo.GetPrivateChatByPairKey(ctx, pairKey)
+	if err == nil {
+		return existing, false, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, false, err
+	}
+
+	// 只有新建私聊时才检查隐私设置和屏蔽
+	if err := s.contactService.CanStartPrivateChat(ctx, userID1, userID2); err != nil {
+		return nil, false, err
+	}
+
 	// 创建聊天对象
 	ObjectUserID1, _ := primitive.ObjectIDFromHex(userID1)
 	ObjectUserID2, _ := primitive.ObjectIDFromHex(userID2)
+	now := time.Now()
 	chat := &domain.Chat{
 		ID:   primitive.NewObjectID(),
-		Type: "private",
+		Type: domain.ChatTypePrivate,
 		Members: []domain.ChatMember{
-			{UserID: ObjectUserID1, Role: "member", JoinedAt: time.Now()},
-			{UserID: ObjectUserID2, Role: "member", JoinedAt: time.Now()},
+			{UserID: ObjectUserID1, Role: "member", JoinedAt: now},
+			{UserID: ObjectUserID2, Role: "member", JoinedAt: now},
 		},
-		CreatedAt: time.Now(),
-		CreatedBy: userID1,
+		CreatedAt:     now,
+		CreatedBy:     userID1,
+		LastMessageAt: now,
+		PairKey:       pairKey,
 	}
 
-	// 保存到数据库
-	if err := s.chatRepo.CreatePrivateChat(ctx, chat); err != nil {
+	// 保存到数据库，并发创建时返回先创建的聊天
+	return s.chatRepo.GetOrCreatePrivateChat(ctx, chat)
+}
+
+func (s *chatService) GetAllChatsByUserID(ctx context.Context, userID string) ([]*domain.Chat, error) {
+	chats, err := s.chatRepo.GetAllChatsByUserID(ctx, userID)
+	if err != nil {
 		return nil, err
 	}
-
-	return chat, nil
+	if err := s.fillUnreadCounts(ctx, userID, chats); err != nil {
+		return nil, err
+	}
+	hideOthersPreferences(userID, chats)
+	return chats, nil
 }
 
-func (s *chatService) GetAllChatsByUserID(ctx context.Context, userID string) ([]*domain.Chat, error) {
-    return s.chatRepo.GetAllChatsByUserID(ctx, userID)
+// fillUnreadCounts 计算每个聊天中当前用户的未读消息数和 @ 数，从已读位置（没有则从加入时间）开始统计
+func (s *chatService) fillUnreadCounts(ctx context.Context, userID string, chats []*domain.Chat) error {
+	since := make(map[string]time.Time, len(chats))
+	for _, chat := range chats {
+		for _, member := range chat.Members {
+			if member.UserID.Hex() != userID {
+				continue
+			}
+			if member.LastReadAt != nil {
+				since[chat.ID.Hex()] = *member.LastReadAt
+			} else {
+				since[chat.ID.Hex()] = member.JoinedAt
+			}
+			break
+		}
+	}
+
+	counts, err := s.messageRepo.CountUnread(ctx, userID, since)
+	if err != nil {
+		return err
+	}
+	for _, chat := range chats {
+		chat.UnreadCount = counts[chat.ID.Hex()].Unread
+		chat.MentionCount = counts[chat.ID.Hex()].Mentions
+	}
+	return nil
 }
 
 func (s *chatService) GetChatMembers(ctx context.Context, chatID string) ([]*domain.User, error) {
@@ -146,4 +225,14 @@ func (s *chatService) CreateGroupChat(ctx context.Context, ownerID string, title
     }
 
     return chat, nil
-}
\ No newline at end of file
+}
+
+// memberRole 返回用户在聊天中的角色，不是成员时返回空字符串
+func memberRole(chat *domain.Chat, userID string) string {
+	for _, member := range chat.Members {
+		if member.UserID.Hex() == userID {
+			return member.Role
+		}
+	}
+	return ""
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxForwardMessages = 50 // 一次最多转发的消息数
+	maxForwardTargets  = 10 // 一次最多转发到的聊天数
+)
+
+func (s *chatService) ForwardMessages(ctx context.Context, userID, sourceChatID string, messageIDs, targetChatIDs []string) ([]*domain.Message, error) {
+	messageIDs = uniqueStrings(messageIDs)
+	targetChatIDs = uniqueStrings(targetChatIDs)
+	if len(messageIDs) == 0 || len(messageIDs) > maxForwardMessages {
+		return nil, fmt.Errorf("%w: 一次可以转发 1 到 %d 条消息", ErrInvalidForward, maxForwardMessages)
+	}
+	if len(targetChatIDs) == 0 || len(targetChatIDs) > maxForwardTargets {
+		return nil, fmt.Errorf("%w: 一次可以转发到 1 到 %d 个聊天", ErrInvalidForward, maxForwardTargets)
+	}
+
+	if err := s.membershipService.CheckMember(ctx, sourceChatID, userID); err != nil {
+		return nil, err
+	}
+	for _, targetChatID := range targetChatIDs {
+		if err := s.membershipService.CheckMember(ctx, targetChatID, userID); err != nil {
+			return nil, err
+		}
+		if err := s.contactService.CheckCanReach(ctx, targetChatID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	originals := make([]*domain.Message, 0, len(messageIDs))
+	for _, messageID := range messageIDs {
+		message, err := s.getChatMessage(ctx, sourceChatID, messageID)
+		if err != nil {
+			return nil, err
+		}
+		if message.DeletedAt != nil {
+			return nil, ErrMessageDeleted
+		}
+		if message.Type == domain.SystemMessage {
+			return nil, fmt.Errorf("%w: 系统消息不能转发", ErrInvalidForward)
+		}
+		originals = append(originals, message)
+	}
+	// 按原始顺序转发
+	sort.SliceStable(originals, func(i, j int) bool { return originals[i].CreatedAt.Before(originals[j].CreatedAt) })
+
+	var forwarded []*domain.Message
+	for _, targetChatID := range targetChatIDs {
+		for _, original := range originals {
+			message := forwardCopy(original, targetChatID, userID)
+			if err := s.messageService.Create(ctx, message); err != nil {
+				return forwarded, err
+			}
+			forwarded = append(forwarded, message)
+		}
+	}
+	return forwarded, nil
+}
+
+// forwardCopy 复制消息内容到目标聊天，文件消息沿用原来的文件 ID，不重新上传
+func forwardCopy(original *domain.Message, chatID, senderID string) *domain.Message {
+	source := original.ForwardedFrom
+	if source == nil {
+		source = &domain.ForwardSource{
+			ChatID:    original.ChatID,
+			MessageID: original.ID.Hex(),
+			SenderID:  original.SenderID,
+			CreatedAt: original.CreatedAt,
+		}
+	}
+	content := original.Content
+	if content.Code != nil {
+		code := *content.Code
+		content.Code = &code
+	}
+	return &domain.Message{
+		ID:            primitive.NewObjectID(),
+		ChatID:        chatID,
+		SenderID:      senderID,
+		Type:          original.Type,
+		Content:       content,
+		CreatedAt:     time.Now(),
+		ForwardedFrom: source,
+	}
+}
+
+// uniqueStrings 去除重复值并保持原有顺序
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
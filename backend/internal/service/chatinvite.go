@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// InviteOptions 创建邀请链接的选项，零值表示不限制
+type InviteOptions struct {
+	ExpiresIn        time.Duration
+	MaxUses          int
+	RequiresApproval bool
+}
+
+func (s *chatService) CreateInvite(ctx context.Context, chatID, operatorID string, opts InviteOptions) (*domain.ChatInvite, error) {
+	if opts.ExpiresIn < 0 || opts.MaxUses < 0 {
+		return nil, ErrInvalidInvite
+	}
+	if err := s.requireGroupAdmin(ctx, chatID, operatorID); err != nil {
+		return nil, err
+	}
+
+	token, err := newInviteToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	invite := &domain.ChatInvite{
+		ID:               primitive.NewObjectID(),
+		ChatID:           chatID,
+		Token:            token,
+		CreatedBy:        operatorID,
+		CreatedAt:        now,
+		MaxUses:          opts.MaxUses,
+		RequiresApproval: opts.RequiresApproval,
+	}
+	if opts.ExpiresIn > 0 {
+		expiresAt := now.Add(opts.ExpiresIn)
+		invite.ExpiresAt = &expiresAt
+	}
+	if err := s.inviteRepo.Create(ctx, invite); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+func (s *chatService) ListInvites(ctx context.Context, chatID, operatorID string) ([]*domain.ChatInvite, error) {
+	if err := s.requireGroupAdmin(ctx, chatID, operatorID); err != nil {
+		return nil, err
+	}
+	return s.inviteRepo.ListActive(ctx, chatID, time.Now())
+}
+
+func (s *chatService) RevokeInvite(ctx context.Context, chatID, operatorID, inviteID string) error {
+	if err := s.requireGroupAdmin(ctx, chatID, operatorID); err != nil {
+		return err
+	}
+	if !primitive.IsValidObjectID(inviteID) {
+		return ErrInviteNotFound
+	}
+	revoked, err := s.inviteRepo.Revoke(ctx, chatID, inviteID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrInviteNotFound
+	}
+	return nil
+}
+
+func (s *chatService) JoinByInvite(ctx context.Context, token, userID string) (*JoinResult, error) {
+	invite, err := s.inviteRepo.GetByToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInviteNotFound
+		}
+		return nil, err
+	}
+	if !invite.Active(time.Now()) {
+		return nil, ErrInviteExpired
+	}
+
+	chat, err := s.getGroupChat(ctx, invite.ChatID)
+	if err != nil {
+		if errors.Is(err, ErrNotChatMember) {
+			// 群聊已被删除
+			return nil, ErrInviteExpired
+		}
+		return nil, err
+	}
+	return s.join(ctx, chat, userID, invite, "")
+}
+
+// requireGroupAdmin 检查操作者是群聊的群主或管理员
+func (s *chatService) requireGroupAdmin(ctx context.Context, chatID, operatorID string) error {
+	chat, err := s.getGroupChat(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	role := memberRole(chat, operatorID)
+	if role != domain.RoleOwner && role != domain.RoleAdmin {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// newInviteToken 生成邀请链接使用的随机令牌
+func newInviteToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
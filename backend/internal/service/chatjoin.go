@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const maxJoinMessageLength = 200
+
+// JoinResult 加入群聊的结果：直接加入时 Change 不为 nil，需要审批时 Request 不为 nil，
+// 两者都为 nil 表示用户已是成员
+type JoinResult struct {
+	Chat           *domain.Chat
+	Change         *MemberChange
+	Request        *domain.JoinRequest
+	RequestCreated bool     // 新建的申请，已有待处理的申请时为 false
+	AdminIDs       []string // 需要通知的群主和管理员
+}
+
+// JoinDecision 处理入群申请的结果，拒绝时 Change 为 nil
+type JoinDecision struct {
+	Request *domain.JoinRequest
+	Change  *MemberChange
+}
+
+func (s *chatService) JoinChat(ctx context.Context, chatID, userID, message string) (*JoinResult, error) {
+	if len([]rune(message)) > maxJoinMessageLength {
+		message = string([]rune(message)[:maxJoinMessageLength])
+	}
+	chat, err := s.getGroupChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return s.join(ctx, chat, userID, nil, message)
+}
+
+// join 将用户加入群聊，群聊或邀请链接需要审批时改为创建入群申请。
+// 不通过邀请链接加入时，只有公开且不需要审批的群聊可以直接加入
+func (s *chatService) join(ctx context.Context, chat *domain.Chat, userID string, invite *domain.ChatInvite, message string) (*JoinResult, error) {
+	if memberRole(chat, userID) != "" {
+		return &JoinResult{Chat: chat}, nil
+	}
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	chatID := chat.ID.Hex()
+	now := time.Now()
+
+	needsApproval := chat.JoinApproval
+	if invite != nil {
+		needsApproval = needsApproval || invite.RequiresApproval
+	} else {
+		needsApproval = needsApproval || !chat.Public
+	}
+	if needsApproval {
+		// 需要审批时由管理员把关，不占用邀请链接的使用次数
+		request := &domain.JoinRequest{
+			ID:        primitive.NewObjectID(),
+			ChatID:    chatID,
+			UserID:    userID,
+			Message:   message,
+			Status:    domain.JoinRequestPending,
+			CreatedAt: now,
+		}
+		if invite != nil {
+			request.InviteID = invite.ID.Hex()
+		}
+		request, created, err := s.joinRequestRepo.Create(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		return &JoinResult{Request: request, RequestCreated: created, AdminIDs: adminIDs(chat)}, nil
+	}
+
+	if invite != nil {
+		// 先占用次数再加入，保证并发使用时不会超过次数限制
+		used, err := s.inviteRepo.Use(ctx, invite.ID.Hex(), now)
+		if err != nil {
+			return nil, err
+		}
+		if !used {
+			return nil, ErrInviteExpired
+		}
+	}
+	added, err := s.chatRepo.AddMember(ctx, chatID, domain.ChatMember{
+		UserID:   user.ID,
+		Role:     domain.RoleMember,
+		JoinedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		return &JoinResult{Chat: chat}, nil
+	}
+	s.membershipService.Invalidate(chatID)
+
+	text := displayName(user) + " 加入了群聊"
+	if invite != nil {
+		text = displayName(user) + " 通过邀请链接加入了群聊"
+	}
+	change := s.memberChange(ctx, chatID, userID, domain.MemberJoined, []string{userID}, "", text)
+	if chat, err = s.chatRepo.GetChatByID(ctx, chatID); err != nil {
+		return nil, err
+	}
+	return &JoinResult{Chat: chat, Change: change}, nil
+}
+
+func (s *chatService) ListJoinRequests(ctx context.Context, chatID, operatorID string) ([]*domain.JoinRequest, error) {
+	if err := s.requireGroupAdmin(ctx, chatID, operatorID); err != nil {
+		return nil, err
+	}
+	return s.joinRequestRepo.ListPending(ctx, chatID)
+}
+
+func (s *chatService) ApproveJoinRequest(ctx context.Context, chatID, operatorID, requestID string) (*JoinDecision, error) {
+	request, err := s.resolveJoinRequest(ctx, chatID, operatorID, requestID, domain.JoinRequestApproved)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.getUser(ctx, request.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	added, err := s.chatRepo.AddMember(ctx, chatID, domain.ChatMember{
+		UserID:   user.ID,
+		Role:     domain.RoleMember,
+		JoinedAt: *request.HandledAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	decision := &JoinDecision{Request: request}
+	if !added {
+		return decision, nil
+	}
+	s.membershipService.Invalidate(chatID)
+
+	text := s.displayNameByID(ctx, operatorID) + " 通过了 " + displayName(user) + " 的入群申请"
+	decision.Change = s.memberChange(ctx, chatID, operatorID, domain.MemberAdded, []string{request.UserID}, "", text)
+	return decision, nil
+}
+
+func (s *chatService) RejectJoinRequest(ctx context.Context, chatID, operatorID, requestID string) (*JoinDecision, error) {
+	request, err := s.resolveJoinRequest(ctx, chatID, operatorID, requestID, domain.JoinRequestRejected)
+	if err != nil {
+		return nil, err
+	}
+	return &JoinDecision{Request: request}, nil
+}
+
+// resolveJoinRequest 检查权限并将待处理的申请标记为指定状态
+func (s *chatService) resolveJoinRequest(ctx context.Context, chatID, operatorID, requestID, status string) (*domain.JoinRequest, error) {
+	if err := s.requireGroupAdmin(ctx, chatID, operatorID); err != nil {
+		return nil, err
+	}
+	if !primitive.IsValidObjectID(requestID) {
+		return nil, ErrJoinRequestNotFound
+	}
+	request, err := s.joinRequestRepo.GetByID(ctx, requestID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrJoinRequestNotFound
+		}
+		return nil, err
+	}
+	if request.ChatID != chatID {
+		return nil, ErrJoinRequestNotFound
+	}
+	if request.Status != domain.JoinRequestPending {
+		return nil, ErrJoinRequestHandled
+	}
+
+	now := time.Now()
+	resolved, err := s.joinRequestRepo.Resolve(ctx, requestID, status, operatorID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !resolved {
+		return nil, ErrJoinRequestHandled
+	}
+	request.Status = status
+	request.HandledBy = operatorID
+	request.HandledAt = &now
+	return request, nil
+}
+
+// adminIDs 返回群主和管理员的 ID
+func adminIDs(chat *domain.Chat) []string {
+	var ids []string
+	for _, member := range chat.Members {
+		if member.Role == domain.RoleOwner || member.Role == domain.RoleAdmin {
+			ids = append(ids, member.UserID.Hex())
+		}
+	}
+	return ids
+}
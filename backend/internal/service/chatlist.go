@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChatListOptions 聊天列表的查询选项
+type ChatListOptions struct {
+	Cursor   string // 上一页返回的 NextCursor，为空表示第一页
+	Limit    int
+	Archived bool // true 时只返回已归档的聊天
+}
+
+// ChatPage 聊天列表的一页，按最后活跃时间倒序；未归档列表的第一页最前面是全部置顶的聊天，置顶的聊天不参与分页
+type ChatPage struct {
+	Chats      []*domain.Chat `json:"chats"`
+	HasMore    bool           `json:"hasMore"`
+	NextCursor string         `json:"nextCursor,omitempty"` // 传给下一次请求的 cursor
+}
+
+func (s *chatService) ListChats(ctx context.Context, userID string, opts ChatListOptions) (*ChatPage, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	query := domain.ChatListQuery{UserID: userID, Limit: limit + 1, Archived: opts.Archived}
+	if !opts.Archived {
+		pinned := false
+		query.Pinned = &pinned
+	}
+	if opts.Cursor != "" {
+		beforeAt, beforeID, err := decodeTimeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query.BeforeAt = beforeAt
+		query.BeforeID = beforeID
+	}
+
+	chats, err := s.chatRepo.ListChats(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	page := &ChatPage{Chats: chats}
+	if len(chats) > limit {
+		page.Chats = chats[:limit]
+		page.HasMore = true
+		last := page.Chats[limit-1]
+		page.NextCursor = encodeTimeCursor(last.LastMessageAt, last.ID.Hex())
+	}
+
+	if !opts.Archived && opts.Cursor == "" {
+		pinned := true
+		pinnedChats, err := s.chatRepo.ListChats(ctx, domain.ChatListQuery{UserID: userID, Limit: maxPageSize, Pinned: &pinned})
+		if err != nil {
+			return nil, err
+		}
+		page.Chats = append(pinnedChats, page.Chats...)
+	}
+
+	if err := s.fillUnreadCounts(ctx, userID, page.Chats); err != nil {
+		return nil, err
+	}
+	hideOthersPreferences(userID, page.Chats)
+	return page, nil
+}
+
+// hideOthersPreferences 清除其他成员的置顶、免打扰和归档设置
+func hideOthersPreferences(userID string, chats []*domain.Chat) {
+	for _, chat := range chats {
+		for i := range chat.Members {
+			if chat.Members[i].UserID.Hex() != userID {
+				chat.Members[i].HidePreferences()
+			}
+		}
+	}
+}
+
+// encodeTimeCursor 将时间（毫秒，与 MongoDB 的时间精度一致）和文档 ID 编码为不透明的游标，
+// 用于按时间倒序、同一时间按 ID 倒序的分页
+func encodeTimeCursor(at time.Time, id string) string {
+	raw := strconv.FormatInt(at.UnixMilli(), 10) + ":" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeTimeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	millis, id, ok := strings.Cut(string(raw), ":")
+	if !ok || !primitive.IsValidObjectID(id) {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return time.UnixMilli(ms), id, nil
+}
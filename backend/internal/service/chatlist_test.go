@@ -0,0 +1,55 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimeCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		at   time.Time
+	}{
+		{name: "recent", at: time.Date(2024, 5, 1, 12, 30, 15, 123_000_000, time.UTC)},
+		{name: "zero time", at: time.Time{}},
+		{name: "before epoch", at: time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	const id = "65f0c0ffee0000000000abcd"
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, gotID, err := decodeTimeCursor(encodeTimeCursor(tt.at, id))
+			if err != nil {
+				t.Fatalf("decodeTimeCursor: %v", err)
+			}
+			if !at.Equal(tt.at) || gotID != id {
+				t.Errorf("decoded (%v, %s), want (%v, %s)", at, gotID, tt.at, id)
+			}
+		})
+	}
+}
+
+func TestDecodeTimeCursorInvalid(t *testing.T) {
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "!!!"},
+		{name: "padded base64", cursor: base64.URLEncoding.EncodeToString([]byte("1:65f0c0ffee0000000000abcd"))},
+		{name: "missing separator", cursor: encode("1714566615123")},
+		{name: "invalid id", cursor: encode("1714566615123:abc")},
+		{name: "invalid millis", cursor: encode("soon:65f0c0ffee0000000000abcd")},
+		{name: "empty millis", cursor: encode(":65f0c0ffee0000000000abcd")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeTimeCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeTimeCursor(%q) error = %v, want ErrInvalidCursor", tt.cursor, err)
+			}
+		})
+	}
+}
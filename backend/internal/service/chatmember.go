@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MemberChange 成员变化的结果：需要推送的事件和写入聊天的系统消息
+type MemberChange struct {
+	Event         *domain.MemberEvent
+	SystemMessage *domain.Message // 系统消息保存失败时为 nil
+}
+
+func (s *chatService) AddMembers(ctx context.Context, chatID, operatorID string, userIDs []string) (*MemberChange, error) {
+	chat, err := s.getGroupChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	role := memberRole(chat, operatorID)
+	if role != domain.RoleOwner && role != domain.RoleAdmin {
+		return nil, ErrPermissionDenied
+	}
+
+	// 先校验所有用户，避免写入一部分后才发现无效的用户
+	var users []*domain.User
+	seen := make(map[string]bool)
+	for _, userID := range userIDs {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+
+		user, err := s.getUser(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	var added []string
+	var names []string
+	var addErr error
+	for _, user := range users {
+		ok, err := s.chatRepo.AddMember(ctx, chatID, domain.ChatMember{
+			UserID:   user.ID,
+			Role:     domain.RoleMember,
+			JoinedAt: time.Now(),
+		})
+		if err != nil {
+			addErr = err
+			break
+		}
+		if ok {
+			added = append(added, user.ID.Hex())
+			names = append(names, displayName(user))
+		}
+	}
+	if len(added) == 0 {
+		return nil, addErr
+	}
+	s.membershipService.Invalidate(chatID)
+
+	// 中途写入失败时，已添加的成员仍然生效，连同错误一起返回
+	text := s.displayNameByID(ctx, operatorID) + " 邀请 " + strings.Join(names, "、") + " 加入了群聊"
+	return s.memberChange(ctx, chatID, operatorID, domain.MemberAdded, added, "", text), addErr
+}
+
+func (s *chatService) RemoveMember(ctx context.Context, chatID, operatorID, userID string) (*MemberChange, error) {
+	if userID == operatorID {
+		return s.LeaveChat(ctx, chatID, userID)
+	}
+
+	chat, err := s.getGroupChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	operatorRole := memberRole(chat, operatorID)
+	targetRole := memberRole(chat, userID)
+	if targetRole == "" {
+		return nil, ErrTargetNotMember
+	}
+	// 群主可以移除任何人，管理员只能移除普通成员
+	if operatorRole != domain.RoleOwner && (operatorRole != domain.RoleAdmin || targetRole != domain.RoleMember) {
+		return nil, ErrPermissionDenied
+	}
+
+	removed, err := s.chatRepo.RemoveMember(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return nil, ErrTargetNotMember
+	}
+	s.membershipService.Invalidate(chatID)
+
+	text := s.displayNameByID(ctx, operatorID) + " 将 " + s.displayNameByID(ctx, userID) + " 移出了群聊"
+	return s.memberChange(ctx, chatID, operatorID, domain.MemberRemoved, []string{userID}, "", text), nil
+}
+
+func (s *chatService) LeaveChat(ctx context.Context, chatID, userID string) (*MemberChange, error) {
+	chat, err := s.getGroupChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if memberRole(chat, userID) == domain.RoleOwner {
+		return nil, ErrOwnerCannotLeave
+	}
+
+	removed, err := s.chatRepo.RemoveMember(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return nil, ErrNotChatMember
+	}
+	s.membershipService.Invalidate(chatID)
+
+	text := s.displayNameByID(ctx, userID) + " 退出了群聊"
+	return s.memberChange(ctx, chatID, userID, domain.MemberLeft, []string{userID}, "", text), nil
+}
+
+func (s *chatService) SetMemberRole(ctx context.Context, chatID, operatorID, userID, role string) (*MemberChange, error) {
+	if role != domain.RoleAdmin && role != domain.RoleMember {
+		return nil, ErrInvalidRole
+	}
+
+	chat, err := s.getGroupChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if memberRole(chat, operatorID) != domain.RoleOwner {
+		return nil, ErrPermissionDenied
+	}
+	switch memberRole(chat, userID) {
+	case "":
+		return nil, ErrTargetNotMember
+	case domain.RoleOwner:
+		return nil, ErrPermissionDenied
+	case role:
+		return nil, nil
+	}
+
+	updated, err := s.chatRepo.UpdateMemberRole(ctx, chatID, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrTargetNotMember
+	}
+	s.membershipService.Invalidate(chatID)
+
+	text := s.displayNameByID(ctx, operatorID) + " 将 " + s.displayNameByID(ctx, userID) + " 设为管理员"
+	if role == domain.RoleMember {
+		text = s.displayNameByID(ctx, operatorID) + " 取消了 " + s.displayNameByID(ctx, userID) + " 的管理员身份"
+	}
+	return s.memberChange(ctx, chatID, operatorID, domain.MemberRoleChanged, []string{userID}, role, text), nil
+}
+
+func (s *chatService) TransferOwnership(ctx context.Context, chatID, operatorID, newOwnerID string) (*MemberChange, error) {
+	chat, err := s.getGroupChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if memberRole(chat, operatorID) != domain.RoleOwner {
+		return nil, ErrPermissionDenied
+	}
+	if newOwnerID == operatorID {
+		return nil, nil
+	}
+	if memberRole(chat, newOwnerID) == "" {
+		return nil, ErrTargetNotMember
+	}
+
+	transferred, err := s.chatRepo.TransferOwnership(ctx, chatID, operatorID, newOwnerID)
+	if err != nil {
+		return nil, err
+	}
+	if !transferred {
+		// 并发修改导致条件不满足
+		return nil, ErrPermissionDenied
+	}
+	s.membershipService.Invalidate(chatID)
+
+	text := s.displayNameByID(ctx, operatorID) + " 将群主转让给了 " + s.displayNameByID(ctx, newOwnerID)
+	return s.memberChange(ctx, chatID, operatorID, domain.MemberOwnerTransferred, []string{operatorID, newOwnerID}, domain.RoleOwner, text), nil
+}
+
+// getGroupChat 获取群聊，不是群聊时返回 ErrNotGroupChat
+func (s *chatService) getGroupChat(ctx context.Context, chatID string) (*domain.Chat, error) {
+	if !primitive.IsValidObjectID(chatID) {
+		return nil, ErrNotChatMember
+	}
+	chat, err := s.chatRepo.GetChatByID(ctx, chatID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotChatMember
+		}
+		return nil, err
+	}
+	if chat.Type != domain.ChatTypeGroup {
+		return nil, ErrNotGroupChat
+	}
+	return chat, nil
+}
+
+func (s *chatService) getUser(ctx context.Context, userID string) (*domain.User, error) {
+	if !primitive.IsValidObjectID(userID) {
+		return nil, ErrUserNotFound
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// memberChange 构造成员变化事件并在聊天中写入系统消息
+func (s *chatService) memberChange(ctx context.Context, chatID, operatorID, action string, userIDs []string, role, text string) *MemberChange {
+	return &MemberChange{
+		Event: &domain.MemberEvent{
+			ChatID:     chatID,
+			Action:     action,
+			OperatorID: operatorID,
+			UserIDs:    userIDs,
+			Role:       role,
+		},
+		SystemMessage: s.postSystemMessage(ctx, chatID, operatorID, text),
+	}
+}
+
+// postSystemMessage 在聊天中写入一条系统消息，失败时只记录日志
+func (s *chatService) postSystemMessage(ctx context.Context, chatID, operatorID, text string) *domain.Message {
+	message := &domain.Message{
+		ID:        primitive.NewObjectID(),
+		ChatID:    chatID,
+		SenderID:  operatorID,
+		Type:      domain.SystemMessage,
+		Content:   domain.MessageContent{Text: text},
+		CreatedAt: time.Now(),
+	}
+	if err := s.messageService.Create(ctx, message); err != nil {
+		log.Printf("error saving system message for chat %s: %v", chatID, err)
+		return nil
+	}
+	return message
+}
+
+func (s *chatService) displayNameByID(ctx context.Context, userID string) string {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "用户"
+	}
+	return displayName(user)
+}
+
+// displayName 优先使用昵称，没有昵称时使用用户名
+func displayName(user *domain.User) string {
+	if user.Profile.Nickname != "" {
+		return user.Profile.Nickname
+	}
+	return user.Username
+}
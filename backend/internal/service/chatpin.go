@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxPinsPerChat 每个聊天最多置顶的消息数
+const maxPinsPerChat = 20
+
+// PinChange 置顶或取消置顶的结果，SystemMessage 仅置顶时非空
+type PinChange struct {
+	Update        *domain.PinUpdate
+	SystemMessage *domain.Message
+}
+
+// PinMessage 置顶消息，群聊需要群主或管理员权限，私聊双方都可以置顶
+func (s *chatService) PinMessage(ctx context.Context, chatID, operatorID, messageID string) (*PinChange, error) {
+	chat, err := s.getPinnableChat(ctx, chatID, operatorID)
+	if err != nil {
+		return nil, err
+	}
+	message, err := s.getChatMessage(ctx, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.DeletedAt != nil {
+		return nil, ErrMessageDeleted
+	}
+	if message.Type == domain.SystemMessage {
+		return nil, ErrMessageNotPinnable
+	}
+
+	pin := domain.PinnedMessage{MessageID: messageID, PinnedBy: operatorID, PinnedAt: time.Now()}
+	added, err := s.chatRepo.AddPin(ctx, chatID, pin, maxPinsPerChat)
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		// 先前读取的聊天可能已过期，重新读取以区分重复置顶和数量达到上限
+		if current, err := s.chatRepo.GetChatByID(ctx, chatID); err == nil {
+			chat = current
+		}
+		for _, existing := range chat.Pins {
+			if existing.MessageID == messageID {
+				return nil, ErrMessageAlreadyPinned
+			}
+		}
+		return nil, ErrPinLimitReached
+	}
+
+	pin.Message = message
+	return &PinChange{
+		Update: &domain.PinUpdate{
+			MessageID:  messageID,
+			OperatorID: operatorID,
+			Pinned:     true,
+			Pin:        &pin,
+		},
+		SystemMessage: s.postSystemMessage(ctx, chatID, operatorID, s.displayNameByID(ctx, operatorID)+" 置顶了一条消息"),
+	}, nil
+}
+
+// UnpinMessage 取消置顶，权限与置顶相同
+func (s *chatService) UnpinMessage(ctx context.Context, chatID, operatorID, messageID string) (*PinChange, error) {
+	if _, err := s.getPinnableChat(ctx, chatID, operatorID); err != nil {
+		return nil, err
+	}
+	removed, err := s.chatRepo.RemovePin(ctx, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return nil, ErrMessageNotPinned
+	}
+	return &PinChange{
+		Update: &domain.PinUpdate{MessageID: messageID, OperatorID: operatorID},
+	}, nil
+}
+
+// ListPins 按置顶时间倒序返回置顶消息，已被撤回的消息不返回
+func (s *chatService) ListPins(ctx context.Context, chatID string) ([]*domain.PinnedMessage, error) {
+	chat, err := s.chatRepo.GetChatByID(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	pins := []*domain.PinnedMessage{}
+	if len(chat.Pins) == 0 {
+		return pins, nil
+	}
+
+	ids := make([]string, 0, len(chat.Pins))
+	for _, pin := range chat.Pins {
+		ids = append(ids, pin.MessageID)
+	}
+	messages, err := s.messageRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*domain.Message, len(messages))
+	for _, message := range messages {
+		byID[message.ID.Hex()] = message
+	}
+
+	for i := range chat.Pins {
+		pin := chat.Pins[i]
+		message, ok := byID[pin.MessageID]
+		if !ok || message.DeletedAt != nil {
+			continue
+		}
+		pin.Message = message
+		pins = append(pins, &pin)
+	}
+	sort.SliceStable(pins, func(i, j int) bool { return pins[i].PinnedAt.After(pins[j].PinnedAt) })
+	return pins, nil
+}
+
+// getPinnableChat 获取聊天并校验操作者有置顶权限
+func (s *chatService) getPinnableChat(ctx context.Context, chatID, operatorID string) (*domain.Chat, error) {
+	if !primitive.IsValidObjectID(chatID) {
+		return nil, ErrNotChatMember
+	}
+	chat, err := s.chatRepo.GetChatByID(ctx, chatID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotChatMember
+		}
+		return nil, err
+	}
+	// 路由中间件已校验聊天成员身份
+	role := memberRole(chat, operatorID)
+	if chat.Type == domain.ChatTypeGroup && role != domain.RoleOwner && role != domain.RoleAdmin {
+		return nil, ErrPermissionDenied
+	}
+	return chat, nil
+}
+
+// getChatMessage 获取消息并校验属于该聊天
+func (s *chatService) getChatMessage(ctx context.Context, chatID, messageID string) (*domain.Message, error) {
+	if !primitive.IsValidObjectID(messageID) {
+		return nil, ErrMessageNotFound
+	}
+	message, err := s.messageRepo.GetByID(ctx, messageID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+	if message.ChatID != chatID {
+		return nil, ErrMessageNotFound
+	}
+	return message, nil
+}
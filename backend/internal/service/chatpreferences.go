@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+)
+
+func (s *chatService) UpdatePreferences(ctx context.Context, chatID, userID string, update domain.MemberPreferencesUpdate) (*domain.ChatMember, error) {
+	now := time.Now()
+	if update.MutedUntil != nil && (update.Muted == nil || !*update.Muted || !update.MutedUntil.After(now)) {
+		return nil, ErrInvalidPreferences
+	}
+
+	updated, err := s.chatRepo.UpdateMemberPreferences(ctx, chatID, userID, update, now)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrNotChatMember
+	}
+	if update.Muted != nil {
+		// 成员缓存中记录了免打扰状态，用于通知推送
+		s.membershipService.Invalidate(chatID)
+	}
+
+	chat, err := s.chatRepo.GetChatByID(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range chat.Members {
+		if chat.Members[i].UserID.Hex() == userID {
+			return &chat.Members[i], nil
+		}
+	}
+	return nil, ErrNotChatMember
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxTitleLength        = 64
+	maxDescriptionLength  = 512
+	maxAnnouncementLength = 2000
+)
+
+// ChatSettingsRequest 群设置修改请求，nil 字段不修改；Announcement 为空字符串时清除公告
+type ChatSettingsRequest struct {
+	Title        *string
+	AvatarFileID *string // 已上传的图片文件 ID
+	Description  *string
+	Announcement *string
+	JoinApproval *bool // 加入群聊是否需要管理员审批
+	Public       *bool // 是否允许不通过邀请链接直接加入
+}
+
+// SettingsChange 群设置修改的结果
+type SettingsChange struct {
+	Chat           *domain.Chat
+	SystemMessages []*domain.Message
+}
+
+func (s *chatService) UpdateSettings(ctx context.Context, chatID, operatorID string, request ChatSettingsRequest) (*SettingsChange, error) {
+	chat, err := s.getGroupChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	role := memberRole(chat, operatorID)
+	if role != domain.RoleOwner && role != domain.RoleAdmin {
+		return nil, ErrPermissionDenied
+	}
+
+	var update domain.ChatSettingsUpdate
+	if request.Title != nil {
+		title := strings.TrimSpace(*request.Title)
+		if title == "" || utf8.RuneCountInString(title) > maxTitleLength {
+			return nil, fmt.Errorf("%w: 群名称不能为空且不超过 %d 个字符", ErrInvalidChatSettings, maxTitleLength)
+		}
+		update.Title = &title
+	}
+	if request.Description != nil {
+		if utf8.RuneCountInString(*request.Description) > maxDescriptionLength {
+			return nil, fmt.Errorf("%w: 群简介不能超过 %d 个字符", ErrInvalidChatSettings, maxDescriptionLength)
+		}
+		update.Description = request.Description
+	}
+	if request.Announcement != nil {
+		text := strings.TrimSpace(*request.Announcement)
+		if utf8.RuneCountInString(text) > maxAnnouncementLength {
+			return nil, fmt.Errorf("%w: 群公告不能超过 %d 个字符", ErrInvalidChatSettings, maxAnnouncementLength)
+		}
+		update.Announcement = &domain.Announcement{Text: text, UpdatedBy: operatorID, UpdatedAt: time.Now()}
+	}
+	update.JoinApproval = request.JoinApproval
+	update.Public = request.Public
+	if request.AvatarFileID != nil {
+		avatar, err := s.avatarURL(ctx, operatorID, *request.AvatarFileID)
+		if err != nil {
+			return nil, err
+		}
+		update.Avatar = &avatar
+	}
+
+	updated, err := s.chatRepo.UpdateSettings(ctx, chatID, update)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &SettingsChange{Chat: updated}
+	operatorName := s.displayNameByID(ctx, operatorID)
+	if update.Title != nil && *update.Title != chat.Title {
+		text := operatorName + " 将群名称修改为 " + *update.Title
+		if message := s.postSystemMessage(ctx, chatID, operatorID, text); message != nil {
+			change.SystemMessages = append(change.SystemMessages, message)
+		}
+	}
+	if update.Announcement != nil && update.Announcement.Text != "" {
+		if message := s.postSystemMessage(ctx, chatID, operatorID, operatorName+" 更新了群公告"); message != nil {
+			change.SystemMessages = append(change.SystemMessages, message)
+		}
+	}
+	return change, nil
+}
+
+// avatarURL 校验头像文件是当前用户上传的图片，返回文件地址
+func (s *chatService) avatarURL(ctx context.Context, operatorID, fileID string) (string, error) {
+	if !primitive.IsValidObjectID(fileID) {
+		return "", ErrFileNotFound
+	}
+	file, err := s.fileRepo.GetByID(ctx, fileID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", ErrFileNotFound
+		}
+		return "", err
+	}
+	if file.UploaderID.Hex() != operatorID {
+		return "", ErrPermissionDenied
+	}
+	if !strings.HasPrefix(file.Type, "image/") {
+		return "", fmt.Errorf("%w: 头像必须是图片文件", ErrInvalidChatSettings)
+	}
+	return file.URL, nil
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"github.com/baoerzuikeai/Imsystem/internal/repository/interfaces"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const maxFriendRequestMessageLength = 200
+
+// FriendRequestResult 发送好友申请的结果
+type FriendRequestResult struct {
+	Request *domain.FriendRequest
+	Created bool // 新建的申请，已有待处理的申请时为 false
+	// 对方已向自己发出过申请时直接成为联系人，Request 为对方的申请
+	Accepted bool
+}
+
+// FriendRequests 用户待处理的好友申请
+type FriendRequests struct {
+	Incoming []*domain.FriendRequest `json:"incoming"`
+	Outgoing []*domain.FriendRequest `json:"outgoing"`
+}
+
+type ContactService interface {
+	SendRequest(ctx context.Context, fromUserID, toUserID, message string) (*FriendRequestResult, error)
+	// 接受或拒绝发给自己的好友申请
+	AcceptRequest(ctx context.Context, userID, requestID string) (*domain.FriendRequest, error)
+	DeclineRequest(ctx context.Context, userID, requestID string) (*domain.FriendRequest, error)
+	ListRequests(ctx context.Context, userID string) (*FriendRequests, error)
+
+	ListContacts(ctx context.Context, userID string) ([]*domain.User, error)
+	RemoveContact(ctx context.Context, userID, contactID string) error
+
+	UpdatePrivacy(ctx context.Context, userID string, privacy domain.Privacy) error
+	// 检查用户是否可以向目标用户发起私聊
+	CanStartPrivateChat(ctx context.Context, userID, targetID string) error
+
+	// 屏蔽和取消屏蔽用户
+	BlockUser(ctx context.Context, userID, targetID string) error
+	UnblockUser(ctx context.Context, userID, targetID string) error
+	ListBlocked(ctx context.Context, userID string) ([]*domain.User, error)
+	// userID 是否屏蔽了 targetID
+	IsBlocked(ctx context.Context, userID, targetID string) (bool, error)
+	// 检查用户发出的内容能否到达聊天中的其他人：私聊对方屏蔽了用户时返回 ErrBlocked，群聊不受屏蔽影响
+	CheckCanReach(ctx context.Context, chatID, userID string) error
+	// 从用户列表中去掉屏蔽了 viewerID 的用户
+	FilterBlockers(ctx context.Context, viewerID string, users []*domain.User) ([]*domain.User, error)
+}
+
+type contactService struct {
+	contactRepo       interfaces.ContactRepository
+	blockRepo         interfaces.BlockRepository
+	userRepo          interfaces.UserRepository
+	membershipService MembershipService
+
+	blockedCache    map[string]*blockedEntry // userID -> 该用户屏蔽的用户
+	blockedVersions map[string]uint64        // 每次屏蔽或取消屏蔽加一，查询期间发生过变化的结果不写入缓存
+	blockedMutex    sync.RWMutex
+}
+
+func NewContactService(contactRepo interfaces.ContactRepository, blockRepo interfaces.BlockRepository, userRepo interfaces.UserRepository,
+	membershipService MembershipService) ContactService {
+	return &contactService{
+		contactRepo:       contactRepo,
+		blockRepo:         blockRepo,
+		userRepo:          userRepo,
+		membershipService: membershipService,
+		blockedCache:      make(map[string]*blockedEntry),
+		blockedVersions:   make(map[string]uint64),
+	}
+}
+
+func (s *contactService) SendRequest(ctx context.Context, fromUserID, toUserID, message string) (*FriendRequestResult, error) {
+	if fromUserID == toUserID {
+		return nil, ErrInvalidContact
+	}
+	if _, err := s.getUser(ctx, toUserID); err != nil {
+		return nil, err
+	}
+	if err := s.checkNotBlocked(ctx, toUserID, fromUserID); err != nil {
+		return nil, err
+	}
+	isContact, err := s.contactRepo.IsContact(ctx, fromUserID, toUserID)
+	if err != nil {
+		return nil, err
+	}
+	if isContact {
+		return nil, ErrAlreadyContact
+	}
+
+	// 对方已经发来申请时，视为同意对方的申请
+	reverse, err := s.contactRepo.GetPendingRequest(ctx, toUserID, fromUserID)
+	if err == nil {
+		accepted, err := s.AcceptRequest(ctx, fromUserID, reverse.ID.Hex())
+		if err != nil {
+			return nil, err
+		}
+		return &FriendRequestResult{Request: accepted, Accepted: true}, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	if len([]rune(message)) > maxFriendRequestMessageLength {
+		message = string([]rune(message)[:maxFriendRequestMessageLength])
+	}
+	request, created, err := s.contactRepo.CreateRequest(ctx, &domain.FriendRequest{
+		ID:         primitive.NewObjectID(),
+		FromUserID: fromUserID,
+		ToUserID:   toUserID,
+		Message:    message,
+		Status:     domain.FriendRequestPending,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &FriendRequestResult{Request: request, Created: created}, nil
+}
+
+func (s *contactService) AcceptRequest(ctx context.Context, userID, requestID string) (*domain.FriendRequest, error) {
+	request, err := s.resolveRequest(ctx, userID, requestID, domain.FriendRequestAccepted)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.contactRepo.AddContact(ctx, request.FromUserID, request.ToUserID, *request.HandledAt); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func (s *contactService) DeclineRequest(ctx context.Context, userID, requestID string) (*domain.FriendRequest, error) {
+	return s.resolveRequest(ctx, userID, requestID, domain.FriendRequestDeclined)
+}
+
+// resolveRequest 将发给 userID 的待处理申请标记为指定状态
+func (s *contactService) resolveRequest(ctx context.Context, userID, requestID, status string) (*domain.FriendRequest, error) {
+	if !primitive.IsValidObjectID(requestID) {
+		return nil, ErrFriendRequestNotFound
+	}
+	request, err := s.contactRepo.GetRequestByID(ctx, requestID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrFriendRequestNotFound
+		}
+		return nil, err
+	}
+	if request.ToUserID != userID {
+		return nil, ErrFriendRequestNotFound
+	}
+	if request.Status != domain.FriendRequestPending {
+		return nil, ErrFriendRequestHandled
+	}
+
+	now := time.Now()
+	resolved, err := s.contactRepo.ResolveRequest(ctx, requestID, status, now)
+	if err != nil {
+		return nil, err
+	}
+	if !resolved {
+		return nil, ErrFriendRequestHandled
+	}
+	request.Status = status
+	request.HandledAt = &now
+	return request, nil
+}
+
+func (s *contactService) ListRequests(ctx context.Context, userID string) (*FriendRequests, error) {
+	incoming, err := s.contactRepo.ListPendingRequests(ctx, userID, true)
+	if err != nil {
+		return nil, err
+	}
+	outgoing, err := s.contactRepo.ListPendingRequests(ctx, userID, false)
+	if err != nil {
+		return nil, err
+	}
+	return &FriendRequests{Incoming: incoming, Outgoing: outgoing}, nil
+}
+
+func (s *contactService) ListContacts(ctx context.Context, userID string) ([]*domain.User, error) {
+	contactIDs, err := s.contactRepo.ListContactIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.getUsers(ctx, contactIDs)
+}
+
+func (s *contactService) RemoveContact(ctx context.Context, userID, contactID string) error {
+	removed, err := s.contactRepo.RemoveContact(ctx, userID, contactID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrNotContact
+	}
+	return nil
+}
+
+func (s *contactService) UpdatePrivacy(ctx context.Context, userID string, privacy domain.Privacy) error {
+	switch privacy.WhoCanMessage {
+	case domain.MessageFromEveryone, domain.MessageFromContacts:
+	default:
+		return ErrInvalidPrivacy
+	}
+	return s.userRepo.UpdatePrivacy(ctx, userID, privacy)
+}
+
+func (s *contactService) CanStartPrivateChat(ctx context.Context, userID, targetID string) error {
+	if userID == targetID {
+		return ErrInvalidContact
+	}
+	target, err := s.getUser(ctx, targetID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkNotBlocked(ctx, targetID, userID); err != nil {
+		return err
+	}
+	if target.Privacy.AllowsStrangerChat() {
+		return nil
+	}
+	isContact, err := s.contactRepo.IsContact(ctx, targetID, userID)
+	if err != nil {
+		return err
+	}
+	if !isContact {
+		return ErrPrivacyRestricted
+	}
+	return nil
+}
+
+// checkNotBlocked 被 userID 屏蔽时返回 ErrBlocked
+func (s *contactService) checkNotBlocked(ctx context.Context, userID, targetID string) error {
+	blocked, err := s.IsBlocked(ctx, userID, targetID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+	return nil
+}
+
+func (s *contactService) getUser(ctx context.Context, userID string) (*domain.User, error) {
+	if !primitive.IsValidObjectID(userID) {
+		return nil, ErrUserNotFound
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// getUsers 按 ids 的顺序批量获取用户，已被删除的用户跳过
+func (s *contactService) getUsers(ctx context.Context, ids []string) ([]*domain.User, error) {
+	users, err := s.userRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*domain.User, len(users))
+	for _, user := range users {
+		byID[user.ID.Hex()] = user
+	}
+	ordered := make([]*domain.User, 0, len(users))
+	for _, id := range ids {
+		if user, ok := byID[id]; ok {
+			ordered = append(ordered, user)
+		}
+	}
+	return ordered, nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import "errors"
+
+// 业务错误，handler 层根据这些错误映射 HTTP 状态码或 WebSocket 错误帧
+var (
+	ErrMessageNotFound     = errors.New("消息不存在")
+	ErrNotMessageSender    = errors.New("只能操作自己发送的消息")
+	ErrMessageNotEditable  = errors.New("该类型的消息不支持编辑")
+	ErrEmptyContent        = errors.New("消息内容不能为空")
+	ErrMessageDeleted      = errors.New("消息已被撤回或删除")
+	ErrRecallExpired       = errors.New("已超过可撤回的时间")
+	ErrPermissionDenied    = errors.New("无权限执行该操作")
+	ErrInvalidReference    = errors.New("引用的消息不存在或不属于该聊天")
+	ErrInvalidEmoji        = errors.New("表情不合法")
+	ErrDuplicateMessage    = errors.New("消息已发送")
+	ErrInvalidCursor       = errors.New("分页游标无效")
+	ErrNotChatMember       = errors.New("不是该聊天的成员")
+	ErrNotGroupChat        = errors.New("该操作仅适用于群聊")
+	ErrTargetNotMember     = errors.New("该用户不是聊天成员")
+	ErrOwnerCannotLeave    = errors.New("群主需要先转让群主才能退出群聊")
+	ErrInvalidRole         = errors.New("角色不合法")
+	ErrUserNotFound        = errors.New("用户不存在")
+	ErrFileNotFound        = errors.New("文件不存在")
+	ErrInvalidChatSettings = errors.New("群设置不合法")
+	ErrInvalidInvite       = errors.New("邀请链接参数不合法")
+	ErrInviteNotFound      = errors.New("邀请链接不存在")
+	ErrInviteExpired       = errors.New("邀请链接已失效")
+	ErrJoinRequestNotFound = errors.New("入群申请不存在")
+	ErrJoinRequestHandled  = errors.New("入群申请已被处理")
+
+	ErrInvalidContact        = errors.New("不能添加自己为联系人")
+	ErrAlreadyContact        = errors.New("对方已经是你的联系人")
+	ErrNotContact            = errors.New("对方不是你的联系人")
+	ErrFriendRequestNotFound = errors.New("好友申请不存在")
+	ErrFriendRequestHandled  = errors.New("好友申请已被处理")
+	ErrInvalidPrivacy        = errors.New("隐私设置不合法")
+	ErrPrivacyRestricted     = errors.New("对方只接受联系人的私聊")
+	ErrBlocked               = errors.New("对方已将你屏蔽")
+	ErrInvalidBlock          = errors.New("不能屏蔽自己")
+	ErrNotBlocked            = errors.New("未屏蔽该用户")
+	ErrInvalidPreferences    = errors.New("免打扰截止时间必须晚于当前时间")
+	ErrMentionNotAllowed     = errors.New("只有群主和管理员可以在大群中使用 @all")
+	ErrInvalidSearch         = errors.New("检索条件不合法")
+	ErrMessageNotPinnable    = errors.New("系统消息不能置顶")
+	ErrMessageAlreadyPinned  = errors.New("消息已置顶")
+	ErrMessageNotPinned      = errors.New("消息未置顶")
+	ErrPinLimitReached       = errors.New("置顶消息数量已达上限")
+	ErrInvalidForward        = errors.New("转发参数不合法")
+
+	ErrInvalidSchedule          = errors.New("定时时间必须晚于当前时间且不超过一年")
+	ErrScheduledMessageNotFound = errors.New("定时消息不存在")
+	ErrScheduledMessageHandled  = errors.New("定时消息已发送或已取消")
+)
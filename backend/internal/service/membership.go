@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"github.com/baoerzuikeai/Imsystem/internal/repository/interfaces"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// membershipCacheTTL 成员缓存的有效期，成员变化时会主动失效
+const membershipCacheTTL = 5 * time.Minute
+
+// MembershipService 聊天成员鉴权，HTTP 接口、WebSocket 帧和广播共用，并缓存成员列表
+type MembershipService interface {
+	// 校验用户是否为聊天成员，聊天不存在或不是成员时返回 ErrNotChatMember
+	CheckMember(ctx context.Context, chatID, userID string) error
+	// 返回用户在聊天中的角色，不是成员时返回 ErrNotChatMember
+	GetRole(ctx context.Context, chatID, userID string) (string, error)
+	// 返回聊天所有成员的 ID
+	GetMemberIDs(ctx context.Context, chatID string) ([]string, error)
+	// 返回聊天中在给定时间没有开启免打扰的成员 ID
+	GetNotifyIDs(ctx context.Context, chatID string, now time.Time) ([]string, error)
+	// 返回私聊中另一方的 ID，群聊返回空字符串
+	GetPrivatePeer(ctx context.Context, chatID, userID string) (string, error)
+	// 成员变化后使缓存失效
+	Invalidate(chatID string)
+}
+
+type membershipEntry struct {
+	roles     map[string]string // userID -> role
+	memberIDs []string
+	muted     map[string]domain.ChatMember // 开启了免打扰的成员
+	private   bool
+	expiresAt time.Time
+}
+
+type membershipService struct {
+	chatRepo interfaces.ChatRepository
+	cache    map[string]*membershipEntry
+	versions map[string]uint64 // 每次 Invalidate 加一，查询期间发生过失效的结果不写入缓存
+	mutex    sync.RWMutex
+}
+
+func NewMembershipService(chatRepo interfaces.ChatRepository) MembershipService {
+	return &membershipService{
+		chatRepo: chatRepo,
+		cache:    make(map[string]*membershipEntry),
+		versions: make(map[string]uint64),
+	}
+}
+
+func (s *membershipService) CheckMember(ctx context.Context, chatID, userID string) error {
+	_, err := s.GetRole(ctx, chatID, userID)
+	return err
+}
+
+func (s *membershipService) GetRole(ctx context.Context, chatID, userID string) (string, error) {
+	entry, err := s.load(ctx, chatID)
+	if err != nil {
+		return "", err
+	}
+	role, ok := entry.roles[userID]
+	if !ok {
+		return "", ErrNotChatMember
+	}
+	return role, nil
+}
+
+func (s *membershipService) GetMemberIDs(ctx context.Context, chatID string) ([]string, error) {
+	entry, err := s.load(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return entry.memberIDs, nil
+}
+
+func (s *membershipService) GetNotifyIDs(ctx context.Context, chatID string, now time.Time) ([]string, error) {
+	entry, err := s.load(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	notifyIDs := make([]string, 0, len(entry.memberIDs))
+	for _, memberID := range entry.memberIDs {
+		if member, ok := entry.muted[memberID]; ok && member.IsMuted(now) {
+			continue
+		}
+		notifyIDs = append(notifyIDs, memberID)
+	}
+	return notifyIDs, nil
+}
+
+func (s *membershipService) GetPrivatePeer(ctx context.Context, chatID, userID string) (string, error) {
+	entry, err := s.load(ctx, chatID)
+	if err != nil {
+		return "", err
+	}
+	if !entry.private {
+		return "", nil
+	}
+	for _, memberID := range entry.memberIDs {
+		if memberID != userID {
+			return memberID, nil
+		}
+	}
+	return "", nil
+}
+
+func (s *membershipService) Invalidate(chatID string) {
+	s.mutex.Lock()
+	delete(s.cache, chatID)
+	s.versions[chatID]++
+	s.mutex.Unlock()
+}
+
+// load 优先从缓存读取成员，缓存缺失或过期时查询数据库
+func (s *membershipService) load(ctx context.Context, chatID string) (*membershipEntry, error) {
+	s.mutex.RLock()
+	entry, ok := s.cache[chatID]
+	version := s.versions[chatID]
+	s.mutex.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry, nil
+	}
+
+	if !primitive.IsValidObjectID(chatID) {
+		return nil, ErrNotChatMember
+	}
+	chat, err := s.chatRepo.GetChatByID(ctx, chatID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotChatMember
+		}
+		return nil, err
+	}
+
+	entry = &membershipEntry{
+		roles:     make(map[string]string, len(chat.Members)),
+		memberIDs: make([]string, 0, len(chat.Members)),
+		muted:     make(map[string]domain.ChatMember),
+		private:   chat.Type == domain.ChatTypePrivate,
+		expiresAt: time.Now().Add(membershipCacheTTL),
+	}
+	for _, member := range chat.Members {
+		memberID := member.UserID.Hex()
+		entry.roles[memberID] = member.Role
+		entry.memberIDs = append(entry.memberIDs, memberID)
+		if member.Muted {
+			entry.muted[memberID] = member
+		}
+	}
+
+	// 查询期间成员发生变化时，读到的可能是变化之前的成员，只用于本次调用
+	s.mutex.Lock()
+	if s.versions[chatID] == version {
+		s.cache[chatID] = entry
+	}
+	s.mutex.Unlock()
+	return entry, nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+)
+
+// largeGroupSize 成员数超过该值的群聊中，只有群主和管理员可以使用 @all
+const largeGroupSize = 50
+
+// mentionPattern 匹配 @username，@ 前面不能紧跟字母或数字，避免把邮箱地址当作提及
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_])@([\p{L}\p{N}_.\-]+)`)
+
+// resolveMentions 解析群聊文本消息中的 @username、@all 和 @here，把被提及的用户 ID 写入消息
+func (s *messageService) resolveMentions(ctx context.Context, message *domain.Message) error {
+	// 转发的消息不再提醒目标聊天中的成员
+	if message.Type != domain.TextMessage || message.ForwardedFrom != nil || !strings.Contains(message.Content.Text, "@") {
+		return nil
+	}
+	matches := mentionPattern.FindAllStringSubmatch(message.Content.Text, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	names, scope := parseMentions(matches)
+	if len(names) == 0 && scope == "" {
+		return nil
+	}
+
+	// 成员从 MembershipService 的缓存读取，私聊不解析提及
+	peerID, err := s.membershipService.GetPrivatePeer(ctx, message.ChatID, message.SenderID)
+	if err != nil || peerID != "" {
+		return err
+	}
+	memberIDs, err := s.membershipService.GetMemberIDs(ctx, message.ChatID)
+	if err != nil {
+		return err
+	}
+	isMember := make(map[string]bool, len(memberIDs))
+	for _, memberID := range memberIDs {
+		isMember[memberID] = true
+	}
+
+	var mentions []string
+	seen := map[string]bool{message.SenderID: true}
+	add := func(id string) {
+		if isMember[id] && !seen[id] {
+			seen[id] = true
+			mentions = append(mentions, id)
+		}
+	}
+
+	// 只查询正文中出现的用户名，按出现顺序记录
+	if len(names) > 0 {
+		users, err := s.userRepo.GetByUsernames(ctx, names)
+		if err != nil {
+			return err
+		}
+		byUsername := make(map[string]string, len(users))
+		for _, user := range users {
+			byUsername[user.Username] = user.ID.Hex()
+		}
+		for _, name := range names {
+			if id, ok := byUsername[name]; ok {
+				add(id)
+			}
+		}
+	}
+
+	switch scope {
+	case domain.MentionAll:
+		role, err := s.membershipService.GetRole(ctx, message.ChatID, message.SenderID)
+		if err != nil {
+			return err
+		}
+		if len(memberIDs) > largeGroupSize && role != domain.RoleOwner && role != domain.RoleAdmin {
+			return ErrMentionNotAllowed
+		}
+		for _, memberID := range memberIDs {
+			add(memberID)
+		}
+	case domain.MentionHere:
+		members, err := s.userRepo.GetByIDs(ctx, memberIDs)
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			if member.Status.Online {
+				add(member.ID.Hex())
+			}
+		}
+	}
+
+	message.Mentions = mentions
+	message.MentionScope = scope
+	return nil
+}
+
+// parseMentions 从正则匹配结果中取出去重后的用户名（按出现顺序）和 @all/@here 范围，@all 优先于 @here
+func parseMentions(matches [][]string) ([]string, string) {
+	var names []string
+	var scope string
+	seen := make(map[string]bool)
+	for _, match := range matches {
+		name := strings.TrimRight(match[1], ".-")
+		switch name {
+		case "": // 只由 . 和 - 组成，不是用户名
+		case domain.MentionAll:
+			scope = domain.MentionAll
+		case domain.MentionHere:
+			if scope == "" {
+				scope = domain.MentionHere
+			}
+		default:
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names, scope
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		text      string
+		wantNames []string
+		wantScope string
+	}{
+		{text: "hi @alice", wantNames: []string{"alice"}},
+		{text: "@alice at the start", wantNames: []string{"alice"}},
+		{text: "mail bob@example.com please"},
+		{text: "bob@example.com and @carol", wantNames: []string{"carol"}},
+		{text: "thanks @bob.", wantNames: []string{"bob"}},
+		{text: "ask @carol- or @dave...", wantNames: []string{"carol", "dave"}},
+		{text: "@first.last is here", wantNames: []string{"first.last"}},
+		{text: "(@dave)", wantNames: []string{"dave"}},
+		{text: "@张三 你好", wantNames: []string{"张三"}},
+		{text: "你好@李四", wantNames: nil},
+		{text: "你好 @李四，", wantNames: []string{"李四"}},
+		{text: "@alice @bob @alice", wantNames: []string{"alice", "bob"}},
+		{text: "@. and @-"},
+		{text: "@here", wantScope: domain.MentionHere},
+		{text: "@here @all", wantScope: domain.MentionAll},
+		{text: "@all @here @alice", wantNames: []string{"alice"}, wantScope: domain.MentionAll},
+		{text: "@all.", wantScope: domain.MentionAll},
+		{text: "@allison", wantNames: []string{"allison"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			names, scope := parseMentions(mentionPattern.FindAllStringSubmatch(tt.text, -1))
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("names = %q, want %q", names, tt.wantNames)
+			}
+			if scope != tt.wantScope {
+				t.Errorf("scope = %q, want %q", scope, tt.wantScope)
+			}
+		})
+	}
+}
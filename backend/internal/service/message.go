@@ -6,8 +6,41 @@ import (
 	"github.com/baoerzuikeai/Imsystem/internal/domain"
 )
 
+// MessageCursor 历史消息游标，Before/After/Around 取值为消息 ID 或序号，最多指定一个
+type MessageCursor struct {
+	Before string
+	After  string
+	Around string
+	Limit  int
+}
+
+// MessagePage 游标分页结果，消息按时间倒序（最新在前）
+type MessagePage struct {
+	Messages []*domain.Message `json:"messages"`
+	HasMore  bool              `json:"hasMore"`            // 分页方向上是否还有消息，around 时表示是否有更早的消息
+	HasNewer bool              `json:"hasNewer,omitempty"` // 仅 around：是否有更新的消息
+}
+
 type MessageService interface {
+	// 保存新消息。相同发送者重复提交同一 ClientMsgID 时返回 ErrDuplicateMessage，并将 message 替换为已保存的消息
 	Create(ctx context.Context, message *domain.Message) error
 	GetByChatID(ctx context.Context, chatID string, limit, offset int) ([]*domain.Message, error)
+	// 游标分页获取历史消息
+	GetPage(ctx context.Context, chatID string, cursor MessageCursor) (*MessagePage, error)
+	// 按序号获取 afterSeq 之后的消息，用于断线重连后补发
+	GetAfterSeq(ctx context.Context, chatID string, afterSeq int64, limit int) ([]*domain.Message, error)
 	GetChatMembers(ctx context.Context, chatID string) ([]*domain.User, error) // 新增方法
+	// 编辑消息，只有发送者可以编辑，旧内容保存在编辑历史中
+	EditMessage(ctx context.Context, chatID, messageID, editorID, content, language string) (*domain.Message, error)
+	// 撤回/删除消息：发送者可在时间窗口内撤回，群主可删除任意消息，消息以墓碑形式保留。
+	// 消息被置顶时同时取消置顶，并返回取消置顶的变化，否则第二个返回值为 nil
+	DeleteMessage(ctx context.Context, chatID, messageID, operatorID string) (*domain.Message, *domain.PinUpdate, error)
+	// 获取话题根消息及其分页回复
+	GetThread(ctx context.Context, chatID, rootID string, limit, offset int) (*domain.Message, []*domain.Message, error)
+	// 添加或移除表情回应，没有产生变化时返回 nil
+	React(ctx context.Context, chatID, messageID, userID, emoji string, add bool) (*domain.ReactionUpdate, error)
+	// 将成员的已读位置推进到指定消息，位置没有前进时返回 nil
+	MarkRead(ctx context.Context, chatID, userID, messageID string) (*domain.ReadReceipt, error)
+	// 在用户所在的聊天中全文检索文本和代码消息，结果带高亮摘要
+	SearchMessages(ctx context.Context, userID string, opts MessageSearchOptions) (*MessageSearchPage, error)
 }
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+)
+
+const (
+	maxSearchKeywordLength = 100 // 检索关键字的最大字符数
+	snippetLength          = 120 // 检索结果摘要的最大字符数
+	snippetLeading         = 30  // 摘要中第一个匹配位置之前保留的字符数
+)
+
+// MessageSearchOptions 消息检索选项，ChatID、SenderID、Type、From、To 为空时不限制
+type MessageSearchOptions struct {
+	Keyword  string
+	ChatID   string
+	SenderID string
+	Type     domain.MessageType // 只能是 text 或 code
+	From     *time.Time
+	To       *time.Time
+	Cursor   string // 上一页返回的 NextCursor，为空表示第一页
+	Limit    int
+}
+
+// MessageSearchPage 检索结果的一页，按发送时间倒序
+type MessageSearchPage struct {
+	Results    []*domain.MessageSearchHit `json:"results"`
+	HasMore    bool                       `json:"hasMore"`
+	NextCursor string                     `json:"nextCursor,omitempty"` // 传给下一次请求的 cursor
+}
+
+func (s *messageService) SearchMessages(ctx context.Context, userID string, opts MessageSearchOptions) (*MessageSearchPage, error) {
+	keyword := strings.TrimSpace(opts.Keyword)
+	if keyword == "" || utf8.RuneCountInString(keyword) > maxSearchKeywordLength {
+		return nil, ErrInvalidSearch
+	}
+	if opts.From != nil && opts.To != nil && !opts.From.Before(*opts.To) {
+		return nil, ErrInvalidSearch
+	}
+	query := domain.MessageSearchQuery{
+		Keyword:  keyword,
+		SenderID: opts.SenderID,
+		From:     opts.From,
+		To:       opts.To,
+	}
+	switch opts.Type {
+	case "":
+		query.Types = []domain.MessageType{domain.TextMessage, domain.CodeMessage}
+	case domain.TextMessage, domain.CodeMessage:
+		query.Types = []domain.MessageType{opts.Type}
+	default:
+		return nil, ErrInvalidSearch
+	}
+
+	// 只在用户所在的聊天中检索
+	chats, err := s.chatRepo.GetAllChatsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, chat := range chats {
+		if opts.ChatID == "" || chat.ID.Hex() == opts.ChatID {
+			query.ChatIDs = append(query.ChatIDs, chat.ID.Hex())
+		}
+	}
+	if opts.ChatID != "" && len(query.ChatIDs) == 0 {
+		return nil, ErrNotChatMember
+	}
+	if len(query.ChatIDs) == 0 {
+		return &MessageSearchPage{Results: []*domain.MessageSearchHit{}}, nil
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	query.Limit = limit + 1
+	if opts.Cursor != "" {
+		if query.BeforeAt, query.BeforeID, err = decodeTimeCursor(opts.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	messages, err := s.searcher.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	page := &MessageSearchPage{}
+	if len(messages) > limit {
+		messages = messages[:limit]
+		page.HasMore = true
+		last := messages[limit-1]
+		page.NextCursor = encodeTimeCursor(last.CreatedAt, last.ID.Hex())
+	}
+
+	pattern := highlightPattern(keyword)
+	page.Results = make([]*domain.MessageSearchHit, 0, len(messages))
+	for _, message := range messages {
+		hit := &domain.MessageSearchHit{Message: message}
+		hit.Snippet, hit.Highlights = buildSnippet(searchableText(message), pattern)
+		page.Results = append(page.Results, hit)
+	}
+	return page, nil
+}
+
+// indexMessage 更新检索索引，失败只记录日志，不影响消息本身
+func (s *messageService) indexMessage(ctx context.Context, message *domain.Message) {
+	if message.Type != domain.TextMessage && message.Type != domain.CodeMessage {
+		return
+	}
+	if err := s.searcher.Index(ctx, message); err != nil {
+		log.Printf("error indexing message %s: %v", message.ID.Hex(), err)
+	}
+}
+
+func (s *messageService) unindexMessage(ctx context.Context, message *domain.Message) {
+	if err := s.searcher.Remove(ctx, message.ID.Hex()); err != nil {
+		log.Printf("error removing message %s from search index: %v", message.ID.Hex(), err)
+	}
+}
+
+// searchableText 返回消息中参与检索的文本
+func searchableText(message *domain.Message) string {
+	if message.Type == domain.CodeMessage && message.Content.Code != nil {
+		return message.Content.Code.Content
+	}
+	return message.Content.Text
+}
+
+// highlightPattern 根据检索关键字生成忽略大小写的匹配表达式。
+// 关键字按文本索引的语法拆分：双引号包围的短语整体匹配，以 - 开头的排除词不高亮
+func highlightPattern(keyword string) *regexp.Regexp {
+	var terms []string
+	for i, part := range strings.Split(keyword, `"`) {
+		if i%2 == 1 {
+			if phrase := strings.TrimSpace(part); phrase != "" {
+				terms = append(terms, phrase)
+			}
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			if !strings.HasPrefix(word, "-") {
+				terms = append(terms, word)
+			}
+		}
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+	// 较长的词优先匹配
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	for i, term := range terms {
+		terms[i] = regexp.QuoteMeta(term)
+	}
+	return regexp.MustCompile(`(?i)` + strings.Join(terms, "|"))
+}
+
+// buildSnippet 截取第一个匹配位置附近的文本作为摘要，并返回摘要中所有匹配的位置
+func buildSnippet(text string, pattern *regexp.Regexp) (string, []domain.TextRange) {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+
+	start := 0
+	if pattern != nil {
+		if loc := pattern.FindStringIndex(text); loc != nil {
+			start = utf8.RuneCountInString(text[:loc[0]]) - snippetLeading
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+	snippet := string(runes[start:end])
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+
+	highlights := []domain.TextRange{}
+	if pattern != nil {
+		for _, loc := range pattern.FindAllStringIndex(snippet, -1) {
+			from := utf8.RuneCountInString(snippet[:loc[0]])
+			highlights = append(highlights, domain.TextRange{
+				Start: from,
+				End:   from + utf8.RuneCountInString(snippet[loc[0]:loc[1]]),
+			})
+		}
+	}
+	return snippet, highlights
+}
@@ -2,32 +2,434 @@ package service
 
 import (
 	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/baoerzuikeai/Imsystem/internal/domain"
 	"github.com/baoerzuikeai/Imsystem/internal/repository/interfaces"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type messageService struct {
-	messageRepo interfaces.MessageRepository
-	chatRepo    interfaces.ChatRepository
+	messageRepo       interfaces.MessageRepository
+	chatRepo          interfaces.ChatRepository
+	userRepo          interfaces.UserRepository
+	membershipService MembershipService
+	searcher          interfaces.MessageSearcher
+	recallWindow      time.Duration // 发送者可撤回消息的时间窗口
 }
 
-func NewMessageService(messageRepo interfaces.MessageRepository, chatRepo interfaces.ChatRepository) MessageService {
+func NewMessageService(messageRepo interfaces.MessageRepository, chatRepo interfaces.ChatRepository, userRepo interfaces.UserRepository,
+	membershipService MembershipService, searcher interfaces.MessageSearcher, recallWindow time.Duration) MessageService {
 	return &messageService{
-		messageRepo: messageRepo,
-		chatRepo:    chatRepo,
+		messageRepo:       messageRepo,
+		chatRepo:          chatRepo,
+		userRepo:          userRepo,
+		membershipService: membershipService,
+		searcher:          searcher,
+		recallWindow:      recallWindow,
 	}
 }
 
 func (s *messageService) Create(ctx context.Context, message *domain.Message) error {
-	return s.messageRepo.Create(ctx, message)
+	if err := s.resolveReferences(ctx, message); err != nil {
+		return err
+	}
+
+	// 先检查重发，避免为重复消息分配序号
+	if message.ClientMsgID != "" {
+		existing, err := s.messageRepo.GetByClientMsgID(ctx, message.SenderID, message.ClientMsgID)
+		if err == nil {
+			*message = *existing
+			return ErrDuplicateMessage
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+	}
+
+	if err := s.resolveMentions(ctx, message); err != nil {
+		return err
+	}
+
+	// 序号在保存前分配：并发保存的顺序可能与序号不同，保存失败或重复时序号留下空缺，补发时已考虑这两种情况
+	seq, err := s.chatRepo.NextSeq(ctx, message.ChatID)
+	if err != nil {
+		return err
+	}
+	message.Seq = seq
+
+	if err := s.messageRepo.Create(ctx, message); err != nil {
+		if message.ClientMsgID != "" && mongo.IsDuplicateKeyError(err) {
+			// 客户端重发，返回已保存的消息
+			existing, getErr := s.messageRepo.GetByClientMsgID(ctx, message.SenderID, message.ClientMsgID)
+			if getErr != nil {
+				return getErr
+			}
+			*message = *existing
+			return ErrDuplicateMessage
+		}
+		return err
+	}
+
+	if message.ThreadRootID != "" {
+		if err := s.messageRepo.IncrementReplyCount(ctx, message.ThreadRootID, message.CreatedAt); err != nil {
+			log.Printf("error updating reply count for thread %s: %v", message.ThreadRootID, err)
+		}
+	}
+	if err := s.chatRepo.UpdateLastMessage(ctx, message.ChatID, message.Preview()); err != nil {
+		log.Printf("error updating last message for chat %s: %v", message.ChatID, err)
+	}
+	s.indexMessage(ctx, message)
+	return nil
+}
+
+// resolveReferences 校验引用和话题消息属于同一聊天，回复话题内的消息时归到同一个根消息下
+func (s *messageService) resolveReferences(ctx context.Context, message *domain.Message) error {
+	if message.ReplyTo != "" {
+		if _, err := s.getChatMessage(ctx, message.ChatID, message.ReplyTo); err != nil {
+			if errors.Is(err, ErrMessageNotFound) {
+				return ErrInvalidReference
+			}
+			return err
+		}
+	}
+
+	if message.ThreadRootID != "" {
+		root, err := s.getChatMessage(ctx, message.ChatID, message.ThreadRootID)
+		if err != nil {
+			if errors.Is(err, ErrMessageNotFound) {
+				return ErrInvalidReference
+			}
+			return err
+		}
+		if root.ThreadRootID != "" {
+			message.ThreadRootID = root.ThreadRootID
+		}
+	}
+	return nil
 }
 
 func (s *messageService) GetByChatID(ctx context.Context, chatID string, limit, offset int) ([]*domain.Message, error) {
 	return s.messageRepo.GetByChatID(ctx, chatID, limit, offset)
 }
 
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+func (s *messageService) GetPage(ctx context.Context, chatID string, cursor MessageCursor) (*MessagePage, error) {
+	limit := cursor.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	switch {
+	case cursor.After != "":
+		anchor, err := s.resolveCursor(ctx, chatID, cursor.After)
+		if err != nil {
+			return nil, err
+		}
+		messages, err := s.messageRepo.GetAfter(ctx, chatID, anchor, limit+1)
+		if err != nil {
+			return nil, err
+		}
+		hasMore := len(messages) > limit
+		if hasMore {
+			messages = messages[:limit]
+		}
+		reverseMessages(messages)
+		return &MessagePage{Messages: messages, HasMore: hasMore}, nil
+
+	case cursor.Around != "":
+		anchor, err := s.resolveCursor(ctx, chatID, cursor.Around)
+		if err != nil {
+			return nil, err
+		}
+		beforeLimit := limit / 2
+		afterLimit := limit - beforeLimit - 1
+		older, err := s.messageRepo.GetBefore(ctx, chatID, anchor, beforeLimit+1)
+		if err != nil {
+			return nil, err
+		}
+		newer, err := s.messageRepo.GetAfter(ctx, chatID, anchor, afterLimit+1)
+		if err != nil {
+			return nil, err
+		}
+
+		page := &MessagePage{
+			HasMore:  len(older) > beforeLimit,
+			HasNewer: len(newer) > afterLimit,
+		}
+		if page.HasMore {
+			older = older[:beforeLimit]
+		}
+		if page.HasNewer {
+			newer = newer[:afterLimit]
+		}
+		reverseMessages(newer)
+		page.Messages = append(append(newer, anchor), older...)
+		return page, nil
+
+	default:
+		var anchor *domain.Message
+		if cursor.Before != "" {
+			var err error
+			if anchor, err = s.resolveCursor(ctx, chatID, cursor.Before); err != nil {
+				return nil, err
+			}
+		}
+		messages, err := s.messageRepo.GetBefore(ctx, chatID, anchor, limit+1)
+		if err != nil {
+			return nil, err
+		}
+		hasMore := len(messages) > limit
+		if hasMore {
+			messages = messages[:limit]
+		}
+		return &MessagePage{Messages: messages, HasMore: hasMore}, nil
+	}
+}
+
+// resolveCursor 将游标（消息 ID 或序号）解析为对应的消息
+func (s *messageService) resolveCursor(ctx context.Context, chatID, value string) (*domain.Message, error) {
+	if seq, err := strconv.ParseInt(value, 10, 64); err == nil {
+		message, err := s.messageRepo.GetBySeq(ctx, chatID, seq)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, ErrInvalidCursor
+			}
+			return nil, err
+		}
+		return message, nil
+	}
+
+	message, err := s.getChatMessage(ctx, chatID, value)
+	if err != nil {
+		if errors.Is(err, ErrMessageNotFound) {
+			return nil, ErrInvalidCursor
+		}
+		return nil, err
+	}
+	return message, nil
+}
+
+func reverseMessages(messages []*domain.Message) {
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+}
+
+func (s *messageService) GetAfterSeq(ctx context.Context, chatID string, afterSeq int64, limit int) ([]*domain.Message, error) {
+	return s.messageRepo.GetAfterSeq(ctx, chatID, afterSeq, limit)
+}
+
 func (s *messageService) GetChatMembers(ctx context.Context, chatID string) ([]*domain.User, error) {
 	// 假设有一个 repository 方法可以根据 chatID 查询成员
 	return s.chatRepo.GetChatMembers(ctx, chatID)
 }
+
+func (s *messageService) EditMessage(ctx context.Context, chatID, messageID, editorID, content, language string) (*domain.Message, error) {
+	if strings.TrimSpace(content) == "" {
+		return nil, ErrEmptyContent
+	}
+
+	message, err := s.getChatMessage(ctx, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.DeletedAt != nil {
+		return nil, ErrMessageDeleted
+	}
+	if message.SenderID != editorID {
+		return nil, ErrNotMessageSender
+	}
+
+	// 根据消息类型构造新内容，文件消息不允许编辑
+	newContent := message.Content
+	switch message.Type {
+	case domain.TextMessage:
+		newContent.Text = content
+	case domain.CodeMessage:
+		if language == "" && message.Content.Code != nil {
+			language = message.Content.Code.Language
+		}
+		newContent.Code = &domain.Code{Language: language, Content: content}
+	default:
+		return nil, ErrMessageNotEditable
+	}
+
+	// 按编辑后的文本重新解析提及，@all 的权限检查同样适用
+	edited := *message
+	edited.Content = newContent
+	edited.Mentions = nil
+	edited.MentionScope = ""
+	if err := s.resolveMentions(ctx, &edited); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	previous := domain.MessageEdit{Content: message.Content, EditedAt: now}
+	if err := s.messageRepo.UpdateContent(ctx, messageID, newContent, edited.Mentions, edited.MentionScope, previous); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+
+	message.Content = newContent
+	message.Mentions = edited.Mentions
+	message.MentionScope = edited.MentionScope
+	message.EditedAt = &now
+	message.EditHistory = append(message.EditHistory, previous)
+	s.refreshLastMessage(ctx, message)
+	s.indexMessage(ctx, message)
+	return message, nil
+}
+
+func (s *messageService) DeleteMessage(ctx context.Context, chatID, messageID, operatorID string) (*domain.Message, *domain.PinUpdate, error) {
+	message, err := s.getChatMessage(ctx, chatID, messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if message.DeletedAt != nil {
+		return nil, nil, ErrMessageDeleted
+	}
+
+	now := time.Now()
+	if message.SenderID != operatorID || now.Sub(message.CreatedAt) > s.recallWindow {
+		// 不是发送者或已超过撤回时间，只有群主可以删除
+		chat, err := s.chatRepo.GetChatByID(ctx, chatID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if chat.Type != domain.ChatTypeGroup || memberRole(chat, operatorID) != domain.RoleOwner {
+			if message.SenderID == operatorID {
+				return nil, nil, ErrRecallExpired
+			}
+			return nil, nil, ErrPermissionDenied
+		}
+	}
+
+	if err := s.messageRepo.SoftDelete(ctx, messageID, operatorID, now); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil, ErrMessageDeleted
+		}
+		return nil, nil, err
+	}
+
+	message.Content = domain.MessageContent{}
+	message.EditHistory = nil
+	message.DeletedAt = &now
+	message.DeletedBy = operatorID
+	s.refreshLastMessage(ctx, message)
+	s.unindexMessage(ctx, message)
+	var unpinned *domain.PinUpdate
+	removed, err := s.chatRepo.RemovePin(ctx, chatID, messageID)
+	if err != nil {
+		log.Printf("error unpinning deleted message %s: %v", messageID, err)
+	} else if removed {
+		unpinned = &domain.PinUpdate{MessageID: messageID, OperatorID: operatorID}
+	}
+	return message, unpinned, nil
+}
+
+// refreshLastMessage 消息是聊天的最后一条消息时更新聊天列表中的摘要
+func (s *messageService) refreshLastMessage(ctx context.Context, message *domain.Message) {
+	if err := s.chatRepo.RefreshLastMessage(ctx, message.ChatID, message.Preview()); err != nil {
+		log.Printf("error refreshing last message for chat %s: %v", message.ChatID, err)
+	}
+}
+
+func (s *messageService) GetThread(ctx context.Context, chatID, rootID string, limit, offset int) (*domain.Message, []*domain.Message, error) {
+	root, err := s.getChatMessage(ctx, chatID, rootID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	replies, err := s.messageRepo.GetThreadReplies(ctx, rootID, limit, offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	return root, replies, nil
+}
+
+// maxEmojiLength 表情字符串的最大长度（字节），组合表情可能由多个码点组成
+const maxEmojiLength = 32
+
+func (s *messageService) React(ctx context.Context, chatID, messageID, userID, emoji string, add bool) (*domain.ReactionUpdate, error) {
+	emoji = strings.TrimSpace(emoji)
+	if emoji == "" || len(emoji) > maxEmojiLength {
+		return nil, ErrInvalidEmoji
+	}
+
+	message, err := s.getChatMessage(ctx, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.DeletedAt != nil {
+		return nil, ErrMessageDeleted
+	}
+
+	var changed bool
+	if add {
+		changed, err = s.messageRepo.AddReaction(ctx, messageID, emoji, userID)
+	} else {
+		changed, err = s.messageRepo.RemoveReaction(ctx, messageID, emoji, userID)
+	}
+	if err != nil || !changed {
+		return nil, err
+	}
+
+	return &domain.ReactionUpdate{
+		MessageID: messageID,
+		Emoji:     emoji,
+		UserID:    userID,
+		Added:     add,
+	}, nil
+}
+
+func (s *messageService) MarkRead(ctx context.Context, chatID, userID, messageID string) (*domain.ReadReceipt, error) {
+	message, err := s.getChatMessage(ctx, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.chatRepo.UpdateReadState(ctx, chatID, userID, messageID, message.CreatedAt)
+	if err != nil || !updated {
+		return nil, err
+	}
+
+	return &domain.ReadReceipt{
+		ChatID:    chatID,
+		UserID:    userID,
+		MessageID: messageID,
+		ReadAt:    message.CreatedAt,
+	}, nil
+}
+
+// getChatMessage 获取属于指定聊天的消息
+func (s *messageService) getChatMessage(ctx context.Context, chatID, messageID string) (*domain.Message, error) {
+	if !primitive.IsValidObjectID(messageID) {
+		return nil, ErrMessageNotFound
+	}
+	message, err := s.messageRepo.GetByID(ctx, messageID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+	if message.ChatID != chatID {
+		return nil, ErrMessageNotFound
+	}
+	return message, nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"github.com/baoerzuikeai/Imsystem/internal/repository/interfaces"
+)
+
+type PresenceService interface {
+	// 更新用户在线状态，返回更新后的状态
+	SetOnline(ctx context.Context, userID string, online bool) (*domain.UserStatus, error)
+	// 返回需要接收该用户在线状态变化的用户 ID（聊天对象，不包括被该用户屏蔽的用户）
+	GetPresenceAudience(ctx context.Context, userID string) ([]string, error)
+	// 服务启动时将所有用户重置为离线
+	ResetPresence(ctx context.Context) error
+}
+
+type presenceService struct {
+	userRepo  interfaces.UserRepository
+	chatRepo  interfaces.ChatRepository
+	blockRepo interfaces.BlockRepository
+}
+
+func NewPresenceService(userRepo interfaces.UserRepository, chatRepo interfaces.ChatRepository, blockRepo interfaces.BlockRepository) PresenceService {
+	return &presenceService{
+		userRepo:  userRepo,
+		chatRepo:  chatRepo,
+		blockRepo: blockRepo,
+	}
+}
+
+func (s *presenceService) SetOnline(ctx context.Context, userID string, online bool) (*domain.UserStatus, error) {
+	status := &domain.UserStatus{
+		Online:   online,
+		LastSeen: time.Now(),
+	}
+	if err := s.userRepo.UpdateStatus(ctx, userID, *status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (s *presenceService) GetPresenceAudience(ctx context.Context, userID string) ([]string, error) {
+	chats, err := s.chatRepo.GetAllChatsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	blockedIDs, err := s.blockRepo.ListBlockedIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, blockedID := range blockedIDs {
+		// 被屏蔽的用户看不到屏蔽者的在线状态
+		seen[blockedID] = true
+	}
+	var audience []string
+	for _, chat := range chats {
+		for _, member := range chat.Members {
+			memberID := member.UserID.Hex()
+			if memberID == userID || seen[memberID] {
+				continue
+			}
+			seen[memberID] = true
+			audience = append(audience, memberID)
+		}
+	}
+	return audience, nil
+}
+
+func (s *presenceService) ResetPresence(ctx context.Context) error {
+	return s.userRepo.ResetOnlineStatus(ctx)
+}
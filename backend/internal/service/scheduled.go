@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"github.com/baoerzuikeai/Imsystem/internal/repository/interfaces"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxScheduleAhead = 365 * 24 * time.Hour // 定时消息最远可以安排的时间
+	claimTimeout     = time.Minute          // 领取后超过该时间仍未完成的投递视为中断，重新投递
+
+	maxDeliveryAttempts = 5                // 临时错误最多重试的次数，超过后标记为投递失败
+	retryBaseDelay      = 30 * time.Second // 第一次重试的等待时间，之后每次翻倍
+)
+
+// ScheduledDraft 定时消息的内容，Content 的含义与 WebSocket 帧相同：文件消息为文件 ID
+type ScheduledDraft struct {
+	Type     domain.MessageType // 为空时为文本消息
+	Content  string
+	Language string // 代码消息的语言
+	FileName string // 文件消息的文件名
+	SendAt   time.Time
+}
+
+// ScheduledUpdate 修改定时消息，nil 表示不修改；文件消息只能修改投递时间
+type ScheduledUpdate struct {
+	Content  *string
+	Language *string
+	SendAt   *time.Time
+}
+
+type ScheduledMessageService interface {
+	// 创建定时消息，需要是聊天成员
+	Schedule(ctx context.Context, userID, chatID string, draft ScheduledDraft) (*domain.ScheduledMessage, error)
+	// 获取用户待投递的定时消息，chatID 为空时返回所有聊天的
+	ListPending(ctx context.Context, userID, chatID string) ([]*domain.ScheduledMessage, error)
+	// 修改或取消待投递的定时消息，只有发送者可以操作
+	Update(ctx context.Context, userID, id string, update ScheduledUpdate) (*domain.ScheduledMessage, error)
+	Cancel(ctx context.Context, userID, id string) error
+	// 投递所有到期的定时消息，返回保存成功的消息和投递失败的定时消息，由调用方广播和通知发送者。
+	// 数据库等临时错误会稍后重试，不计入失败
+	DeliverDue(ctx context.Context, now time.Time) ([]*domain.Message, []*domain.ScheduledMessage, error)
+}
+
+type scheduledMessageService struct {
+	scheduledRepo     interfaces.ScheduledMessageRepository
+	messageService    MessageService
+	membershipService MembershipService
+	contactService    ContactService
+}
+
+func NewScheduledMessageService(scheduledRepo interfaces.ScheduledMessageRepository, messageService MessageService,
+	membershipService MembershipService, contactService ContactService) ScheduledMessageService {
+	return &scheduledMessageService{
+		scheduledRepo:     scheduledRepo,
+		messageService:    messageService,
+		membershipService: membershipService,
+		contactService:    contactService,
+	}
+}
+
+func (s *scheduledMessageService) Schedule(ctx context.Context, userID, chatID string, draft ScheduledDraft) (*domain.ScheduledMessage, error) {
+	if err := s.membershipService.CheckMember(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := checkSendAt(draft.SendAt, now); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(draft.Content) == "" {
+		return nil, ErrEmptyContent
+	}
+
+	message := &domain.ScheduledMessage{
+		ID:        primitive.NewObjectID(),
+		ChatID:    chatID,
+		SenderID:  userID,
+		Type:      draft.Type,
+		SendAt:    draft.SendAt,
+		Status:    domain.ScheduledPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	switch draft.Type {
+	case "", domain.TextMessage:
+		message.Type = domain.TextMessage
+		message.Content.Text = draft.Content
+	case domain.CodeMessage:
+		message.Content.Code = &domain.Code{Language: draft.Language, Content: draft.Content}
+	case domain.FileMessage:
+		message.Content.FileID = draft.Content
+		message.Content.FileName = draft.FileName
+	default:
+		return nil, ErrInvalidSchedule
+	}
+
+	if err := s.scheduledRepo.Create(ctx, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (s *scheduledMessageService) ListPending(ctx context.Context, userID, chatID string) ([]*domain.ScheduledMessage, error) {
+	return s.scheduledRepo.ListPending(ctx, userID, chatID)
+}
+
+func (s *scheduledMessageService) Update(ctx context.Context, userID, id string, update ScheduledUpdate) (*domain.ScheduledMessage, error) {
+	existing, err := s.getOwned(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if update.SendAt != nil {
+		if err := checkSendAt(*update.SendAt, now); err != nil {
+			return nil, err
+		}
+	}
+	var content *domain.MessageContent
+	if update.Content != nil || update.Language != nil {
+		// 与编辑已发送的消息相同，根据消息类型构造新内容
+		newContent := existing.Content
+		switch existing.Type {
+		case domain.TextMessage:
+			if update.Content != nil {
+				newContent.Text = *update.Content
+			}
+			if strings.TrimSpace(newContent.Text) == "" {
+				return nil, ErrEmptyContent
+			}
+		case domain.CodeMessage:
+			code := domain.Code{}
+			if existing.Content.Code != nil {
+				code = *existing.Content.Code
+			}
+			if update.Content != nil {
+				code.Content = *update.Content
+			}
+			if update.Language != nil {
+				code.Language = *update.Language
+			}
+			if strings.TrimSpace(code.Content) == "" {
+				return nil, ErrEmptyContent
+			}
+			newContent.Code = &code
+		default:
+			return nil, ErrMessageNotEditable
+		}
+		content = &newContent
+	}
+
+	updated, err := s.scheduledRepo.UpdatePending(ctx, id, userID, content, update.SendAt, now)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// 读取之后被投递或取消
+			return nil, ErrScheduledMessageHandled
+		}
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (s *scheduledMessageService) Cancel(ctx context.Context, userID, id string) error {
+	if _, err := s.getOwned(ctx, userID, id); err != nil {
+		return err
+	}
+	canceled, err := s.scheduledRepo.Cancel(ctx, id, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !canceled {
+		return ErrScheduledMessageHandled
+	}
+	return nil
+}
+
+// getOwned 获取用户自己待投递的定时消息
+func (s *scheduledMessageService) getOwned(ctx context.Context, userID, id string) (*domain.ScheduledMessage, error) {
+	if !primitive.IsValidObjectID(id) {
+		return nil, ErrScheduledMessageNotFound
+	}
+	message, err := s.scheduledRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrScheduledMessageNotFound
+		}
+		return nil, err
+	}
+	if message.SenderID != userID {
+		return nil, ErrScheduledMessageNotFound
+	}
+	if message.Status != domain.ScheduledPending {
+		return nil, ErrScheduledMessageHandled
+	}
+	return message, nil
+}
+
+func (s *scheduledMessageService) DeliverDue(ctx context.Context, now time.Time) ([]*domain.Message, []*domain.ScheduledMessage, error) {
+	// 服务在投递过程中退出时，已领取的消息在超时后重新投递
+	released, err := s.scheduledRepo.ReleaseStale(ctx, now.Add(-claimTimeout))
+	if err != nil {
+		return nil, nil, err
+	}
+	if released > 0 {
+		log.Printf("released %d interrupted scheduled messages", released)
+	}
+
+	var delivered []*domain.Message
+	var failed []*domain.ScheduledMessage
+	for {
+		scheduled, err := s.scheduledRepo.ClaimDue(ctx, now)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return delivered, failed, nil
+			}
+			return delivered, failed, err
+		}
+
+		message, err := s.deliver(ctx, scheduled)
+		if err != nil {
+			log.Printf("error delivering scheduled message %s: %v", scheduled.ID.Hex(), err)
+			if s.fail(ctx, scheduled, err) {
+				failed = append(failed, scheduled)
+			}
+			continue
+		}
+		if err := s.scheduledRepo.MarkSent(ctx, scheduled.ID.Hex(), message.ID.Hex(), message.CreatedAt); err != nil {
+			log.Printf("error marking scheduled message %s as sent: %v", scheduled.ID.Hex(), err)
+		}
+		delivered = append(delivered, message)
+	}
+}
+
+// fail 处理投递错误：业务错误或重试次数用完时标记为投递失败并返回 true，临时错误稍后重试
+func (s *scheduledMessageService) fail(ctx context.Context, scheduled *domain.ScheduledMessage, deliverErr error) bool {
+	id := scheduled.ID.Hex()
+	now := time.Now()
+	if !isRejectedDelivery(deliverErr) && scheduled.Attempts+1 < maxDeliveryAttempts {
+		next := now.Add(retryBaseDelay << scheduled.Attempts)
+		if err := s.scheduledRepo.Retry(ctx, id, deliverErr.Error(), next, now); err != nil {
+			// 保持投递中状态，领取超时后重新投递
+			log.Printf("error scheduling retry for scheduled message %s: %v", id, err)
+		}
+		return false
+	}
+
+	if err := s.scheduledRepo.MarkFailed(ctx, id, deliverErr.Error(), now); err != nil {
+		log.Printf("error marking scheduled message %s as failed: %v", id, err)
+		return false
+	}
+	scheduled.Status = domain.ScheduledFailed
+	scheduled.Error = deliverErr.Error()
+	scheduled.UpdatedAt = now
+	scheduled.ClaimedAt = nil
+	return true
+}
+
+// isRejectedDelivery 判断投递是否因业务规则被拒绝，这类错误重试也不会成功
+func isRejectedDelivery(err error) bool {
+	for _, rejected := range []error{
+		ErrNotChatMember, ErrBlocked, ErrPrivacyRestricted, ErrPermissionDenied,
+		ErrMentionNotAllowed, ErrInvalidReference, ErrEmptyContent, ErrFileNotFound,
+	} {
+		if errors.Is(err, rejected) {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver 以发送者身份保存消息，发送者已离开聊天或被私聊对方屏蔽时投递失败
+func (s *scheduledMessageService) deliver(ctx context.Context, scheduled *domain.ScheduledMessage) (*domain.Message, error) {
+	if err := s.membershipService.CheckMember(ctx, scheduled.ChatID, scheduled.SenderID); err != nil {
+		return nil, err
+	}
+	if err := s.contactService.CheckCanReach(ctx, scheduled.ChatID, scheduled.SenderID); err != nil {
+		return nil, err
+	}
+
+	message := &domain.Message{
+		ID:          primitive.NewObjectID(),
+		ChatID:      scheduled.ChatID,
+		SenderID:    scheduled.SenderID,
+		ClientMsgID: scheduled.ClientMsgID(),
+		Type:        scheduled.Type,
+		Content:     scheduled.Content,
+		CreatedAt:   time.Now(),
+	}
+	// 中断后重新投递时 ClientMsgID 相同，返回已保存的消息；客户端按消息 ID 去重
+	if err := s.messageService.Create(ctx, message); err != nil && !errors.Is(err, ErrDuplicateMessage) {
+		return nil, err
+	}
+	return message, nil
+}
+
+// checkSendAt 投递时间必须晚于当前时间，且不超过 maxScheduleAhead
+func checkSendAt(sendAt, now time.Time) error {
+	if !sendAt.After(now) || sendAt.Sub(now) > maxScheduleAhead {
+		return ErrInvalidSchedule
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCheckSendAt(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		sendAt  time.Time
+		wantErr bool
+	}{
+		{name: "in the past", sendAt: now.Add(-time.Minute), wantErr: true},
+		{name: "now", sendAt: now, wantErr: true},
+		{name: "just after now", sendAt: now.Add(time.Millisecond)},
+		{name: "within a year", sendAt: now.Add(30 * 24 * time.Hour)},
+		{name: "exactly the limit", sendAt: now.Add(maxScheduleAhead)},
+		{name: "beyond the limit", sendAt: now.Add(maxScheduleAhead + time.Second), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSendAt(tt.sendAt, now)
+			if tt.wantErr && !errors.Is(err, ErrInvalidSchedule) {
+				t.Errorf("checkSendAt() error = %v, want ErrInvalidSchedule", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("checkSendAt() error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestIsRejectedDelivery(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "left the chat", err: ErrNotChatMember, want: true},
+		{name: "blocked", err: ErrBlocked, want: true},
+		{name: "wrapped", err: fmt.Errorf("deliver: %w", ErrMentionNotAllowed), want: true},
+		{name: "database error", err: errors.New("connection reset"), want: false},
+		{name: "deadline", err: fmt.Errorf("find: %w", errors.New("context deadline exceeded")), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRejectedDelivery(tt.err); got != tt.want {
+				t.Errorf("isRejectedDelivery(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/baoerzuikeai/Imsystem/internal/domain"
+	"github.com/baoerzuikeai/Imsystem/internal/service"
 )
 
 const (
@@ -48,38 +50,275 @@ func (c *Client) ReadPump() {
 			continue
 		}
 
-		// 保存消息到数据库
-		msg := &domain.Message{
-			ID:        primitive.NewObjectID(),
-			ChatID:    wsMessage.ChatID,
-			SenderID:  c.UserID,
-			CreatedAt: time.Now(),
-		}
+		c.handleMessage(&wsMessage)
+	}
+}
 
-		switch wsMessage.Type {
-		case WSMessageTypeChat:
-			msg.Type = domain.TextMessage
-			msg.Content.Text = wsMessage.Content.(string)
-		case WSMessageTypeCode:
-			msg.Type = domain.CodeMessage
-			msg.Content.Code = &domain.Code{
-				Language: wsMessage.Language,
-				Content:  wsMessage.Content.(string),
+// handleMessage 根据帧类型分发客户端消息，针对单个聊天的帧先校验成员身份
+func (c *Client) handleMessage(wsMessage *WSMessage) {
+	// typing_start 先限流再鉴权，频繁的输入帧不会每次都查询成员和屏蔽关系
+	if wsMessage.Type == WSMessageTypeTypingStart && c.typingThrottled(wsMessage.ChatID) {
+		return
+	}
+	if wsMessage.Type != WSMessageTypeResume {
+		if err := c.Manager.membershipService.CheckMember(context.Background(), wsMessage.ChatID, c.UserID); err != nil {
+			code, message := errorCode(err)
+			if isChatMessage(wsMessage.Type) {
+				c.sendNack(wsMessage, code, message)
+			} else {
+				c.sendServiceError(err)
 			}
-		case WSMessageTypeFile:
-			msg.Type = domain.FileMessage
-			msg.Content.FileID = wsMessage.Content.(string)
-			msg.Content.FileName = wsMessage.FileName
+			return
 		}
+	}
+	if reachesPeer(wsMessage.Type) {
+		if err := c.Manager.contactService.CheckCanReach(context.Background(), wsMessage.ChatID, c.UserID); err != nil {
+			code, message := errorCode(err)
+			if isChatMessage(wsMessage.Type) {
+				c.sendNack(wsMessage, code, message)
+			} else {
+				c.sendServiceError(err)
+			}
+			return
+		}
+	}
 
-		if err := c.Manager.messageService.Create(context.Background(), msg); err != nil {
-			log.Printf("error saving message: %v", err)
-			continue
+	switch wsMessage.Type {
+	case WSMessageTypeEdit:
+		c.handleEdit(wsMessage)
+	case WSMessageTypeDelete:
+		c.handleDelete(wsMessage)
+	case WSMessageTypeReactionAdd, WSMessageTypeReactionRemove:
+		c.handleReaction(wsMessage)
+	case WSMessageTypeRead:
+		c.handleRead(wsMessage)
+	case WSMessageTypeTypingStart, WSMessageTypeTypingStop:
+		c.handleTyping(wsMessage)
+	case WSMessageTypeResume:
+		c.handleResume(wsMessage)
+	case WSMessageTypeChat, WSMessageTypeCode, WSMessageTypeFile:
+		c.handleChatMessage(wsMessage)
+	default:
+		c.sendError("bad_request", "不支持的消息类型")
+	}
+}
+
+func isChatMessage(messageType WSMessageType) bool {
+	return messageType == WSMessageTypeChat || messageType == WSMessageTypeCode || messageType == WSMessageTypeFile
+}
+
+// reachesPeer 会推送给私聊对方的帧，被对方屏蔽时拒绝
+func reachesPeer(messageType WSMessageType) bool {
+	switch messageType {
+	case WSMessageTypeChat, WSMessageTypeCode, WSMessageTypeFile, WSMessageTypeEdit,
+		WSMessageTypeReactionAdd, WSMessageTypeReactionRemove, WSMessageTypeTypingStart:
+		return true
+	}
+	return false
+}
+
+// handleChatMessage 保存新消息，向发送者回复 ack/nack 并广播给聊天成员
+func (c *Client) handleChatMessage(wsMessage *WSMessage) {
+	content, ok := wsMessage.Content.(string)
+	if !ok {
+		c.sendNack(wsMessage, "bad_request", "content 必须是字符串")
+		return
+	}
+
+	// 保存消息到数据库
+	msg := &domain.Message{
+		ID:          primitive.NewObjectID(),
+		ChatID:      wsMessage.ChatID,
+		SenderID:    c.UserID,
+		ClientMsgID: wsMessage.ClientMsgID,
+		CreatedAt:   time.Now(),
+
+		ReplyTo:      wsMessage.ReplyTo,
+		ThreadRootID: wsMessage.ThreadRootID,
+	}
+
+	switch wsMessage.Type {
+	case WSMessageTypeChat:
+		msg.Type = domain.TextMessage
+		msg.Content.Text = content
+	case WSMessageTypeCode:
+		msg.Type = domain.CodeMessage
+		msg.Content.Code = &domain.Code{
+			Language: wsMessage.Language,
+			Content:  content,
 		}
+	case WSMessageTypeFile:
+		msg.Type = domain.FileMessage
+		msg.Content.FileID = content
+		msg.Content.FileName = wsMessage.FileName
+	}
+
+	if err := c.Manager.messageService.Create(context.Background(), msg); err != nil {
+		if errors.Is(err, service.ErrDuplicateMessage) {
+			// 客户端重发的消息已经保存过，只回复 ack，不再广播
+			c.sendAck(msg)
+			return
+		}
+		log.Printf("error saving message: %v", err)
+		code, message := errorCode(err)
+		c.sendNack(wsMessage, code, message)
+		return
+	}
+
+	c.sendAck(msg)
+
+	// 发送消息即表示停止输入
+	c.Manager.stopTyping(wsMessage.ChatID, c.UserID, nil)
+
+	// 广播消息
+	messageJSON, _ := json.Marshal(msg)
+	c.Manager.Broadcast(wsMessage.ChatID, messageJSON)
+	c.Manager.NotifyMessage(msg)
+}
+
+// sendAck 告知发送者消息已保存
+func (c *Client) sendAck(msg *domain.Message) {
+	c.sendEvent(WSEventAck, msg.ChatID, &AckPayload{
+		ClientMsgID: msg.ClientMsgID,
+		ID:          msg.ID.Hex(),
+		CreatedAt:   msg.CreatedAt,
+	})
+}
+
+// sendNack 告知发送者消息保存失败
+func (c *Client) sendNack(wsMessage *WSMessage, code, message string) {
+	c.sendEvent(WSEventNack, wsMessage.ChatID, &NackPayload{
+		ClientMsgID: wsMessage.ClientMsgID,
+		Code:        code,
+		Message:     message,
+	})
+}
+
+// handleEdit 编辑消息并向聊天成员广播 edited 事件
+func (c *Client) handleEdit(wsMessage *WSMessage) {
+	content, ok := wsMessage.Content.(string)
+	if !ok {
+		c.sendError("bad_request", "content 必须是字符串")
+		return
+	}
+
+	msg, err := c.Manager.messageService.EditMessage(context.Background(), wsMessage.ChatID, wsMessage.MessageID, c.UserID, content, wsMessage.Language)
+	if err != nil {
+		c.sendServiceError(err)
+		return
+	}
+
+	c.Manager.BroadcastEvent(msg.ChatID, WSEventEdited, msg)
+}
+
+// handleDelete 撤回/删除消息并向聊天成员广播 message_deleted 事件，消息被置顶时同时广播取消置顶
+func (c *Client) handleDelete(wsMessage *WSMessage) {
+	msg, unpinned, err := c.Manager.messageService.DeleteMessage(context.Background(), wsMessage.ChatID, wsMessage.MessageID, c.UserID)
+	if err != nil {
+		c.sendServiceError(err)
+		return
+	}
+
+	c.Manager.BroadcastEvent(msg.ChatID, WSEventMessageDeleted, msg)
+	if unpinned != nil {
+		c.Manager.BroadcastEvent(msg.ChatID, WSEventPinned, unpinned)
+	}
+}
+
+// handleReaction 添加或移除表情回应，只广播增量而不是整条消息
+func (c *Client) handleReaction(wsMessage *WSMessage) {
+	add := wsMessage.Type == WSMessageTypeReactionAdd
+	update, err := c.Manager.messageService.React(context.Background(), wsMessage.ChatID, wsMessage.MessageID, c.UserID, wsMessage.Emoji, add)
+	if err != nil {
+		c.sendServiceError(err)
+		return
+	}
+	if update == nil {
+		return
+	}
+
+	c.Manager.BroadcastEvent(wsMessage.ChatID, WSEventReaction, update)
+}
+
+// handleRead 推进已读位置并向聊天成员广播已读回执
+func (c *Client) handleRead(wsMessage *WSMessage) {
+	receipt, err := c.Manager.messageService.MarkRead(context.Background(), wsMessage.ChatID, c.UserID, wsMessage.MessageID)
+	if err != nil {
+		c.sendServiceError(err)
+		return
+	}
+	if receipt == nil {
+		return
+	}
+
+	c.Manager.BroadcastEvent(wsMessage.ChatID, WSEventRead, receipt)
+}
+
+// sendEvent 只向当前连接发送事件
+func (c *Client) sendEvent(eventType WSEventType, chatID string, payload interface{}) {
+	data, err := json.Marshal(&WSEvent{
+		Type:    eventType,
+		ChatID:  chatID,
+		Payload: payload,
+	})
+	if err != nil {
+		log.Printf("error marshaling %s event: %v", eventType, err)
+		return
+	}
+
+	select {
+	case c.Send <- data:
+	default:
+		log.Printf("Client send channel full for UserID %s, ConnectionID %s. Event %s dropped.", c.UserID, c.ID, eventType)
+	}
+}
+
+func (c *Client) sendError(code, message string) {
+	c.sendEvent(WSEventError, "", &WSError{Code: code, Message: message})
+}
+
+// sendServiceError 将业务错误转换为错误帧
+func (c *Client) sendServiceError(err error) {
+	code, message := errorCode(err)
+	if code == "internal" {
+		log.Printf("error handling message from UserID %s: %v", c.UserID, err)
+	}
+	c.sendError(code, message)
+}
 
-		// 广播消息
-		messageJSON, _ := json.Marshal(msg)
-		c.Manager.Broadcast(wsMessage.ChatID, messageJSON)
+// errorCode 将业务错误映射为错误码和可展示的错误信息
+func errorCode(err error) (string, string) {
+	switch {
+	case errors.Is(err, service.ErrMessageNotFound), errors.Is(err, service.ErrTargetNotMember),
+		errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrFileNotFound),
+		errors.Is(err, service.ErrInviteNotFound), errors.Is(err, service.ErrJoinRequestNotFound),
+		errors.Is(err, service.ErrFriendRequestNotFound), errors.Is(err, service.ErrNotContact),
+		errors.Is(err, service.ErrNotBlocked), errors.Is(err, service.ErrMessageNotPinned),
+		errors.Is(err, service.ErrScheduledMessageNotFound):
+		return "not_found", err.Error()
+	case errors.Is(err, service.ErrNotMessageSender), errors.Is(err, service.ErrPermissionDenied),
+		errors.Is(err, service.ErrRecallExpired), errors.Is(err, service.ErrNotChatMember),
+		errors.Is(err, service.ErrPrivacyRestricted), errors.Is(err, service.ErrBlocked),
+		errors.Is(err, service.ErrMentionNotAllowed):
+		return "forbidden", err.Error()
+	case errors.Is(err, service.ErrMessageDeleted), errors.Is(err, service.ErrOwnerCannotLeave),
+		errors.Is(err, service.ErrInviteExpired), errors.Is(err, service.ErrJoinRequestHandled),
+		errors.Is(err, service.ErrAlreadyContact), errors.Is(err, service.ErrFriendRequestHandled),
+		errors.Is(err, service.ErrMessageAlreadyPinned), errors.Is(err, service.ErrPinLimitReached),
+		errors.Is(err, service.ErrScheduledMessageHandled):
+		return "conflict", err.Error()
+	case errors.Is(err, service.ErrMessageNotEditable), errors.Is(err, service.ErrEmptyContent),
+		errors.Is(err, service.ErrInvalidReference), errors.Is(err, service.ErrInvalidEmoji),
+		errors.Is(err, service.ErrInvalidCursor), errors.Is(err, service.ErrNotGroupChat),
+		errors.Is(err, service.ErrInvalidRole), errors.Is(err, service.ErrInvalidChatSettings),
+		errors.Is(err, service.ErrInvalidInvite), errors.Is(err, service.ErrInvalidContact),
+		errors.Is(err, service.ErrInvalidPrivacy), errors.Is(err, service.ErrInvalidBlock),
+		errors.Is(err, service.ErrInvalidPreferences), errors.Is(err, service.ErrInvalidSearch),
+		errors.Is(err, service.ErrMessageNotPinnable), errors.Is(err, service.ErrInvalidForward),
+		errors.Is(err, service.ErrInvalidSchedule):
+		return "bad_request", err.Error()
+	default:
+		return "internal", "服务器内部错误"
 	}
 }
 
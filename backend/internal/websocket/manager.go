@@ -2,9 +2,12 @@ package websocket
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"sync"
+	"time"
 
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
 	"github.com/baoerzuikeai/Imsystem/internal/service"
 	"github.com/gorilla/websocket"
 )
@@ -15,6 +18,11 @@ type Client struct {
 	Socket  *websocket.Conn
 	Send    chan []byte
 	Manager *Manager
+
+	typingSentAt map[string]time.Time // 每个聊天最近一次转发 typing_start 的时间，仅在 ReadPump 中访问
+
+	heldMutex sync.Mutex
+	held      map[string][][]byte // 正在补发的聊天 -> 补发期间暂存的实时广播
 }
 
 type Manager struct {
@@ -24,24 +32,43 @@ type Manager struct {
 	unregister     chan *Client
 	mutex          sync.RWMutex
 	messageService service.MessageService
+
+	presenceService   service.PresenceService
+	membershipService service.MembershipService
+	contactService    service.ContactService
+	presence          chan presenceChange
+	offlineTimers     map[string]*time.Timer // userID -> 离线宽限期计时器，由 mutex 保护
+
+	typing      map[string]*time.Timer // chatID:userID -> 输入状态过期计时器
+	typingMutex sync.Mutex
 }
 
 type BroadcastMessage struct {
-	ChatID  string
-	Message []byte
+	ChatID        string
+	Message       []byte
+	ExcludeUserID string // 不推送给该用户，为空时推送给所有成员
 }
 
-func NewManager(messageService service.MessageService) *Manager {
+func NewManager(messageService service.MessageService, presenceService service.PresenceService, membershipService service.MembershipService,
+	contactService service.ContactService) *Manager {
 	return &Manager{
-		userClients:    make(map[string]map[string]*Client),
-		broadcast:      make(chan *BroadcastMessage),
-		register:       make(chan *Client),
-		unregister:     make(chan *Client),
-		messageService: messageService,
+		userClients:       make(map[string]map[string]*Client),
+		broadcast:         make(chan *BroadcastMessage),
+		register:          make(chan *Client),
+		unregister:        make(chan *Client),
+		messageService:    messageService,
+		presenceService:   presenceService,
+		membershipService: membershipService,
+		contactService:    contactService,
+		presence:          make(chan presenceChange, 256),
+		offlineTimers:     make(map[string]*time.Timer),
+		typing:            make(map[string]*time.Timer),
 	}
 }
 
 func (m *Manager) Start() {
+	go m.runPresence()
+
 	for {
 		select {
 		case client := <-m.register:
@@ -50,8 +77,13 @@ func (m *Manager) Start() {
 				m.userClients[client.UserID] = make(map[string]*Client)
 			}
 			m.userClients[client.UserID][client.ID] = client // client.ID 现在是唯一的 connectionID
+			cameOnline := m.userConnected(client.UserID)
 			log.Printf("Client registered: UserID %s, ConnectionID %s", client.UserID, client.ID)
 			m.mutex.Unlock()
+
+			if cameOnline {
+				m.presence <- presenceChange{UserID: client.UserID, Online: true}
+			}
 		case client := <-m.unregister:
 			m.mutex.Lock()
 			if userConnections, ok := m.userClients[client.UserID]; ok {
@@ -59,6 +91,7 @@ func (m *Manager) Start() {
 					delete(m.userClients[client.UserID], client.ID)
 					if len(m.userClients[client.UserID]) == 0 {
 						delete(m.userClients, client.UserID) // 如果该用户已无任何连接，则删除用户条目
+						m.userDisconnected(client.UserID)
 					}
 					close(client.Send) // 关闭此特定连接的发送通道
 					log.Printf("Client unregistered: UserID %s, ConnectionID %s", client.UserID, client.ID)
@@ -72,7 +105,7 @@ func (m *Manager) Start() {
 }
 
 func (m *Manager) handleBroadcast(broadcastMsg *BroadcastMessage) {
-	members, err := m.messageService.GetChatMembers(context.Background(), broadcastMsg.ChatID) // 这返回 []*domain.User
+	memberIDs, err := m.membershipService.GetMemberIDs(context.Background(), broadcastMsg.ChatID) // 使用缓存的成员列表
 	if err != nil {
 		log.Printf("Error getting chat members for broadcast: %v", err)
 		return
@@ -81,8 +114,10 @@ func (m *Manager) handleBroadcast(broadcastMsg *BroadcastMessage) {
 	m.mutex.RLock() // 加读锁来安全地读取 userClients
 
 	clientsToNotify := []*Client{}
-	for _, member := range members { // member 是 *domain.User
-		userID := member.ID.Hex()
+	for _, userID := range memberIDs {
+		if userID == broadcastMsg.ExcludeUserID {
+			continue
+		}
 		if userConnections, ok := m.userClients[userID]; ok { // 检查该用户是否有活动的连接
 			for _, clientInstance := range userConnections { // 遍历该用户的所有连接
 				clientsToNotify = append(clientsToNotify, clientInstance)
@@ -93,10 +128,7 @@ func (m *Manager) handleBroadcast(broadcastMsg *BroadcastMessage) {
 
 	// 在锁外部进行发送操作，避免长时间持有锁
 	for _, clientInstance := range clientsToNotify {
-		select {
-		case clientInstance.Send <- broadcastMsg.Message:
-			// 消息已发送
-		default:
+		if !clientInstance.deliverLive(broadcastMsg.ChatID, broadcastMsg.Message) {
 			// 发送通道已满，可能客户端处理慢或已断开。
 			// writePump 中有超时和错误处理，它会负责关闭连接并触发 unregister。
 			// 这里可以考虑记录日志，表明某个客户端的通道满了。
@@ -121,3 +153,76 @@ func (m *Manager) Broadcast(chatID string, message []byte) {
 		Message: message,
 	}
 }
+
+// BroadcastEvent 将事件序列化后广播给聊天的所有成员
+func (m *Manager) BroadcastEvent(chatID string, eventType WSEventType, payload interface{}) {
+	m.BroadcastEventExcept(chatID, "", eventType, payload)
+}
+
+// BroadcastEventExcept 将事件广播给聊天中除 excludeUserID 以外的成员
+func (m *Manager) BroadcastEventExcept(chatID, excludeUserID string, eventType WSEventType, payload interface{}) {
+	data, err := json.Marshal(&WSEvent{
+		Type:    eventType,
+		ChatID:  chatID,
+		Payload: payload,
+	})
+	if err != nil {
+		log.Printf("Error marshaling %s event: %v", eventType, err)
+		return
+	}
+	m.broadcast <- &BroadcastMessage{
+		ChatID:        chatID,
+		Message:       data,
+		ExcludeUserID: excludeUserID,
+	}
+}
+
+// SendEventToUser 将事件推送给指定用户的所有连接
+func (m *Manager) SendEventToUser(userID string, eventType WSEventType, payload interface{}) {
+	data, err := json.Marshal(&WSEvent{
+		Type:    eventType,
+		Payload: payload,
+	})
+	if err != nil {
+		log.Printf("Error marshaling %s event: %v", eventType, err)
+		return
+	}
+
+	// 持有读锁发送：unregister 在写锁下关闭 Send 通道，因此这里不会向已关闭的通道发送
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, clientInstance := range m.userClients[userID] {
+		select {
+		case clientInstance.Send <- data:
+		default:
+			log.Printf("Client send channel full for UserID %s, ConnectionID %s. Event %s dropped.", clientInstance.UserID, clientInstance.ID, eventType)
+		}
+	}
+}
+
+// NotifyMessage 向被 @ 的成员推送 mention 事件（不受免打扰影响），
+// 向其他没有开启免打扰的成员（发送者除外）推送新消息通知
+func (m *Manager) NotifyMessage(message *domain.Message) {
+	memberIDs, err := m.membershipService.GetNotifyIDs(context.Background(), message.ChatID, time.Now())
+	if err != nil {
+		log.Printf("Error getting notification recipients for chat %s: %v", message.ChatID, err)
+		return
+	}
+
+	payload := &NotificationPayload{
+		ChatID:    message.ChatID,
+		MessageID: message.ID.Hex(),
+		SenderID:  message.SenderID,
+		Preview:   message.Preview(),
+	}
+	mentioned := make(map[string]bool, len(message.Mentions))
+	for _, userID := range message.Mentions {
+		mentioned[userID] = true
+		m.SendEventToUser(userID, WSEventMention, payload)
+	}
+	for _, memberID := range memberIDs {
+		if memberID != message.SenderID && !mentioned[memberID] {
+			m.SendEventToUser(memberID, WSEventNotification, payload)
+		}
+	}
+}
@@ -1,17 +1,117 @@
 package websocket
 
+import (
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/domain"
+)
+
 type WSMessageType string
 
 const (
-	WSMessageTypeChat WSMessageType = "chat"
-	WSMessageTypeCode WSMessageType = "code"
-	WSMessageTypeFile WSMessageType = "file"
+	WSMessageTypeChat   WSMessageType = "chat"
+	WSMessageTypeCode   WSMessageType = "code"
+	WSMessageTypeFile   WSMessageType = "file"
+	WSMessageTypeEdit   WSMessageType = "edit"   // 编辑已发送的消息
+	WSMessageTypeDelete WSMessageType = "delete" // 撤回/删除消息
+
+	WSMessageTypeReactionAdd    WSMessageType = "reaction_add"    // 添加表情回应
+	WSMessageTypeReactionRemove WSMessageType = "reaction_remove" // 移除表情回应
+	WSMessageTypeRead           WSMessageType = "read"            // 标记已读到某条消息
+	WSMessageTypeTypingStart    WSMessageType = "typing_start"    // 开始输入，不持久化
+	WSMessageTypeTypingStop     WSMessageType = "typing_stop"     // 停止输入，不持久化
+	WSMessageTypeResume         WSMessageType = "resume"          // 重连后请求补发各聊天缺失的消息
 )
 
 type WSMessage struct {
-	Type     WSMessageType `json:"type"`
-	ChatID   string        `json:"chatId"`
-	Content  interface{}   `json:"content"`
-	Language string        `json:"language,omitempty"` // 用于代码消息
-	FileName string        `json:"fileName,omitempty"` // 用于文件消息
+	Type      WSMessageType `json:"type"`
+	ChatID    string        `json:"chatId"`
+	Content   interface{}   `json:"content"`
+	Language  string        `json:"language,omitempty"`  // 用于代码消息
+	FileName  string        `json:"fileName,omitempty"`  // 用于文件消息
+	MessageID string        `json:"messageId,omitempty"` // 用于编辑等针对已有消息的操作
+
+	ReplyTo      string `json:"replyTo,omitempty"`      // 引用的消息 ID
+	ThreadRootID string `json:"threadRootId,omitempty"` // 在话题中回复时的根消息 ID
+	Emoji        string `json:"emoji,omitempty"`        // 用于表情回应
+	ClientMsgID  string `json:"clientMsgId,omitempty"`  // 客户端生成的消息 ID，用于 ack 和去重
+
+	LastSeqs map[string]int64 `json:"lastSeqs,omitempty"` // 用于 resume：chatId -> 客户端已收到且之前没有缺失的最大序号
+}
+
+// WSEventType 服务端推送给客户端的事件类型
+type WSEventType string
+
+const (
+	WSEventEdited         WSEventType = "edited"
+	WSEventMessageDeleted WSEventType = "message_deleted"
+	WSEventReaction       WSEventType = "reaction"
+	WSEventRead           WSEventType = "read"
+	WSEventTypingStart    WSEventType = "typing_start"
+	WSEventTypingStop     WSEventType = "typing_stop"
+	WSEventUserStatus     WSEventType = "user_status_update"
+	WSEventAck            WSEventType = "ack"
+	WSEventNack           WSEventType = "nack"
+	WSEventResumed        WSEventType = "resumed"
+	WSEventResyncRequired WSEventType = "resync_required"
+	WSEventMemberChanged  WSEventType = "member_changed"
+	WSEventChatUpdated    WSEventType = "chat_updated"
+	WSEventError          WSEventType = "error"
+
+	WSEventJoinRequest         WSEventType = "join_request"          // 新的入群申请，推送给群主和管理员
+	WSEventJoinRequestResolved WSEventType = "join_request_resolved" // 入群申请的处理结果，推送给申请人
+
+	WSEventFriendRequest         WSEventType = "friend_request"          // 新的好友申请，推送给被申请人
+	WSEventFriendRequestResolved WSEventType = "friend_request_resolved" // 好友申请的处理结果，推送给申请人
+
+	WSEventPreferences  WSEventType = "chat_preferences" // 聊天偏好变化，同步到该用户的所有连接
+	WSEventNotification WSEventType = "notification"     // 新消息通知，不推送给开启免打扰的成员
+	WSEventMention      WSEventType = "mention"          // 被 @ 的通知，免打扰时也推送
+
+	WSEventPinned WSEventType = "pinned" // 消息被置顶或取消置顶
+
+	WSEventScheduledFailed WSEventType = "scheduled_failed" // 定时消息投递失败，推送给发送者
+)
+
+// WSEvent 服务端推送的事件，新消息本身仍直接以 domain.Message 推送
+type WSEvent struct {
+	Type    WSEventType `json:"type"`
+	ChatID  string      `json:"chatId,omitempty"`
+	Payload interface{} `json:"payload"`
+}
+
+// WSError 错误事件的负载
+type WSError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// AckPayload 消息保存成功后回复给发送者
+type AckPayload struct {
+	ClientMsgID string    `json:"clientMsgId,omitempty"`
+	ID          string    `json:"id"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// NackPayload 消息保存失败时回复给发送者
+type NackPayload struct {
+	ClientMsgID string `json:"clientMsgId,omitempty"`
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+}
+
+// ResumePayload 某个聊天补发完成或需要全量同步时的负载。
+// 补发的消息先于补发期间产生的实时消息到达，已补发的消息不会再通过实时广播重复发送。
+// 序号可能有永久的空缺，客户端收到 resumed 后把 LastSeq 作为新的无缺失序号，跳过其中的空缺
+type ResumePayload struct {
+	ChatID  string `json:"chatId"`
+	LastSeq int64  `json:"lastSeq"` // 已补发到的序号
+}
+
+// NotificationPayload 新消息通知和 mention 事件的负载
+type NotificationPayload struct {
+	ChatID    string                 `json:"chatId"`
+	MessageID string                 `json:"messageId"`
+	SenderID  string                 `json:"senderId"`
+	Preview   *domain.MessagePreview `json:"preview"`
 }
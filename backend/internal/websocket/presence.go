@@ -0,0 +1,79 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// presenceGracePeriod 最后一个连接断开后等待重连的时间，超时才标记为离线
+const presenceGracePeriod = 5 * time.Second
+
+// PresencePayload 在线状态变化事件的负载
+type PresencePayload struct {
+	UserID   string    `json:"userId"`
+	IsOnline bool      `json:"isOnline"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+type presenceChange struct {
+	UserID string
+	Online bool
+}
+
+// userConnected 在用户建立连接后调用，调用方需持有 m.mutex。
+// 返回 true 表示用户从离线变为在线
+func (m *Manager) userConnected(userID string) bool {
+	if timer, ok := m.offlineTimers[userID]; ok {
+		// 宽限期内重连，用户始终视为在线
+		timer.Stop()
+		delete(m.offlineTimers, userID)
+		return false
+	}
+	return len(m.userClients[userID]) == 1
+}
+
+// userDisconnected 在用户最后一个连接断开后调用，调用方需持有 m.mutex。
+// 宽限期结束时仍没有连接才发布离线状态
+func (m *Manager) userDisconnected(userID string) {
+	var timer *time.Timer
+	timer = time.AfterFunc(presenceGracePeriod, func() {
+		m.mutex.Lock()
+		if m.offlineTimers[userID] != timer || len(m.userClients[userID]) > 0 {
+			m.mutex.Unlock()
+			return
+		}
+		delete(m.offlineTimers, userID)
+		m.mutex.Unlock()
+
+		m.presence <- presenceChange{UserID: userID, Online: false}
+	})
+	m.offlineTimers[userID] = timer
+}
+
+// runPresence 按顺序处理在线状态变化：写入数据库并推送给聊天对象
+func (m *Manager) runPresence() {
+	for change := range m.presence {
+		ctx := context.Background()
+		status, err := m.presenceService.SetOnline(ctx, change.UserID, change.Online)
+		if err != nil {
+			log.Printf("Error updating presence for UserID %s: %v", change.UserID, err)
+			continue
+		}
+
+		audience, err := m.presenceService.GetPresenceAudience(ctx, change.UserID)
+		if err != nil {
+			log.Printf("Error getting presence audience for UserID %s: %v", change.UserID, err)
+			continue
+		}
+
+		payload := &PresencePayload{
+			UserID:   change.UserID,
+			IsOnline: status.Online,
+			LastSeen: status.LastSeen,
+		}
+		for _, userID := range audience {
+			m.SendEventToUser(userID, WSEventUserStatus, payload)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// resumeMaxGap 单个聊天最多补发的消息数，超过时要求客户端通过 HTTP 接口全量同步
+const resumeMaxGap = 200
+
+// handleResume 按客户端上报的各聊天序号补发断线期间的消息。
+// 补发期间该聊天的实时广播先暂存，补发完成后再按顺序发送，因此补发的消息总是先于实时消息到达。
+// 序号在保存前分配，并发发送时序号较大的消息可能先保存和广播，重复消息也会留下空缺的序号，
+// 所以客户端上报的是该序号及以下没有缺失的序号，补发只按实际补发过的序号去重，不假设序号连续
+func (c *Client) handleResume(wsMessage *WSMessage) {
+	for chatID, lastSeq := range wsMessage.LastSeqs {
+		if !c.resumeChat(chatID, lastSeq) {
+			return
+		}
+	}
+}
+
+// resumeChat 补发单个聊天的消息，发送通道阻塞超时返回 false
+func (c *Client) resumeChat(chatID string, lastSeq int64) bool {
+	ctx := context.Background()
+	if err := c.Manager.membershipService.CheckMember(ctx, chatID, c.UserID); err != nil {
+		c.sendServiceError(err)
+		return true
+	}
+
+	// 在查询之前开始暂存，查询之后保存的消息不会丢失
+	c.holdLive(chatID)
+	replayed := make(map[int64]bool)
+	defer c.releaseLive(chatID, replayed)
+
+	messages, err := c.Manager.messageService.GetAfterSeq(ctx, chatID, lastSeq, resumeMaxGap+1)
+	if err != nil {
+		c.sendServiceError(err)
+		return true
+	}
+
+	if len(messages) > resumeMaxGap {
+		c.sendEvent(WSEventResyncRequired, chatID, &ResumePayload{ChatID: chatID, LastSeq: lastSeq})
+		return true
+	}
+
+	replayedSeq := lastSeq
+	for _, msg := range messages {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			log.Printf("error marshaling message %s for resume: %v", msg.ID.Hex(), err)
+			continue
+		}
+		if !c.sendBlocking(data) {
+			return false
+		}
+		replayed[msg.Seq] = true
+		replayedSeq = msg.Seq
+	}
+	c.sendEvent(WSEventResumed, chatID, &ResumePayload{ChatID: chatID, LastSeq: replayedSeq})
+	return true
+}
+
+// deliverLive 发送实时广播，聊天正在补发时先暂存。发送通道已满时返回 false
+func (c *Client) deliverLive(chatID string, data []byte) bool {
+	c.heldMutex.Lock()
+	if held, ok := c.held[chatID]; ok {
+		c.held[chatID] = append(held, data)
+		c.heldMutex.Unlock()
+		return true
+	}
+	c.heldMutex.Unlock()
+
+	select {
+	case c.Send <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+// holdLive 开始暂存某个聊天的实时广播
+func (c *Client) holdLive(chatID string) {
+	c.heldMutex.Lock()
+	defer c.heldMutex.Unlock()
+	if c.held == nil {
+		c.held = make(map[string][][]byte)
+	}
+	c.held[chatID] = [][]byte{}
+}
+
+// releaseLive 按顺序发送暂存的实时广播并恢复直接发送，跳过 replayed 中已补发的消息。
+// 序号比已补发消息小、但在查询之后才保存的消息仍然发送。
+// 发送期间新到达的广播继续暂存，直到暂存为空才解除，保证顺序
+func (c *Client) releaseLive(chatID string, replayed map[int64]bool) {
+	for {
+		c.heldMutex.Lock()
+		held := c.held[chatID]
+		if len(held) == 0 {
+			delete(c.held, chatID)
+			c.heldMutex.Unlock()
+			return
+		}
+		c.held[chatID] = [][]byte{}
+		c.heldMutex.Unlock()
+
+		for _, data := range held {
+			var frame struct {
+				Seq int64 `json:"seq"`
+			}
+			if json.Unmarshal(data, &frame) == nil && replayed[frame.Seq] {
+				continue
+			}
+			if !c.sendBlocking(data) {
+				c.heldMutex.Lock()
+				delete(c.held, chatID)
+				c.heldMutex.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// sendBlocking 等待发送通道有空位，补发大量消息时不丢弃。超时返回 false
+func (c *Client) sendBlocking(data []byte) bool {
+	timer := time.NewTimer(writeWait)
+	defer timer.Stop()
+
+	select {
+	case c.Send <- data:
+		return true
+	case <-timer.C:
+		log.Printf("Client send channel blocked for UserID %s, ConnectionID %s. Resume aborted.", c.UserID, c.ID)
+		return false
+	}
+}
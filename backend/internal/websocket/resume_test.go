@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReleaseLive(t *testing.T) {
+	tests := []struct {
+		name     string
+		held     []string
+		replayed map[int64]bool
+		want     []string
+	}{
+		{
+			name: "nothing held",
+			want: nil,
+		},
+		{
+			name:     "skips replayed messages",
+			held:     []string{`{"seq":3}`, `{"seq":4}`, `{"seq":5}`},
+			replayed: map[int64]bool{3: true, 4: true},
+			want:     []string{`{"seq":5}`},
+		},
+		{
+			name:     "keeps lower seq saved after the query",
+			held:     []string{`{"seq":6}`, `{"seq":4}`},
+			replayed: map[int64]bool{5: true, 6: true},
+			want:     []string{`{"seq":4}`},
+		},
+		{
+			name:     "keeps events without seq in order",
+			held:     []string{`{"type":"typing_start"}`, `{"seq":2}`, `{"type":"edited","payload":{"seq":1}}`},
+			replayed: map[int64]bool{1: true},
+			want:     []string{`{"type":"typing_start"}`, `{"seq":2}`, `{"type":"edited","payload":{"seq":1}}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{Send: make(chan []byte, 16)}
+			c.holdLive("chat")
+			for _, frame := range tt.held {
+				if !c.deliverLive("chat", []byte(frame)) {
+					t.Fatalf("deliverLive(%s) = false while held", frame)
+				}
+			}
+			if len(c.Send) != 0 {
+				t.Fatalf("held frames were sent before release")
+			}
+
+			c.releaseLive("chat", tt.replayed)
+			if got := drain(c.Send); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("released %v, want %v", got, tt.want)
+			}
+			if _, ok := c.held["chat"]; ok {
+				t.Errorf("hold not removed after release")
+			}
+		})
+	}
+}
+
+func TestDeliverLiveOnlyHoldsResumingChat(t *testing.T) {
+	c := &Client{Send: make(chan []byte, 16)}
+	c.holdLive("resuming")
+
+	c.deliverLive("other", []byte(`{"seq":1}`))
+	c.deliverLive("resuming", []byte(`{"seq":7}`))
+	if got, want := drain(c.Send), []string{`{"seq":1}`}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("sent %v while holding, want %v", got, want)
+	}
+
+	c.releaseLive("resuming", nil)
+	c.deliverLive("resuming", []byte(`{"seq":8}`))
+	if got, want := drain(c.Send), []string{`{"seq":7}`, `{"seq":8}`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v after release, want %v", got, want)
+	}
+}
+
+func TestDeliverLiveFullChannel(t *testing.T) {
+	c := &Client{Send: make(chan []byte, 1)}
+	if !c.deliverLive("chat", []byte("a")) {
+		t.Fatal("deliverLive with free space = false")
+	}
+	if c.deliverLive("chat", []byte("b")) {
+		t.Error("deliverLive with full channel = true")
+	}
+}
+
+func drain(ch chan []byte) []string {
+	var frames []string
+	for {
+		select {
+		case data := <-ch:
+			frames = append(frames, string(data))
+		default:
+			return frames
+		}
+	}
+}
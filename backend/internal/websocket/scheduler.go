@@ -0,0 +1,58 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/baoerzuikeai/Imsystem/internal/service"
+)
+
+// defaultScheduleInterval 未配置时扫描到期定时消息的间隔
+const defaultScheduleInterval = 5 * time.Second
+
+// Scheduler 定时消息的后台投递任务，定期投递到期的定时消息并广播给聊天成员，投递失败时通知发送者。
+// 待投递的消息保存在数据库中，服务重启后继续投递
+type Scheduler struct {
+	manager          *Manager
+	scheduledService service.ScheduledMessageService
+	interval         time.Duration
+}
+
+func NewScheduler(manager *Manager, scheduledService service.ScheduledMessageService, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = defaultScheduleInterval
+	}
+	return &Scheduler{
+		manager:          manager,
+		scheduledService: scheduledService,
+		interval:         interval,
+	}
+}
+
+func (s *Scheduler) Start() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	// 启动时立即投递停机期间到期的消息
+	s.deliver()
+	for range ticker.C {
+		s.deliver()
+	}
+}
+
+func (s *Scheduler) deliver() {
+	messages, failed, err := s.scheduledService.DeliverDue(context.Background(), time.Now())
+	if err != nil {
+		log.Printf("error delivering scheduled messages: %v", err)
+	}
+	for _, msg := range messages {
+		messageJSON, _ := json.Marshal(msg)
+		s.manager.Broadcast(msg.ChatID, messageJSON)
+		s.manager.NotifyMessage(msg)
+	}
+	for _, scheduled := range failed {
+		s.manager.SendEventToUser(scheduled.SenderID, WSEventScheduledFailed, scheduled)
+	}
+}
@@ -0,0 +1,82 @@
+package websocket
+
+import "time"
+
+const (
+	typingTimeout  = 5 * time.Second // 未收到 typing_stop 时输入状态自动过期
+	typingInterval = 2 * time.Second // 同一连接在同一聊天中转发 typing_start 的最小间隔
+)
+
+// TypingPayload 输入状态事件的负载
+type TypingPayload struct {
+	ChatID string `json:"chatId"`
+	UserID string `json:"userId"`
+}
+
+// handleTyping 处理输入状态帧，只转发给聊天中的其他成员，不写入数据库
+func (c *Client) handleTyping(wsMessage *WSMessage) {
+	if wsMessage.ChatID == "" {
+		return
+	}
+
+	if wsMessage.Type == WSMessageTypeTypingStop {
+		c.Manager.stopTyping(wsMessage.ChatID, c.UserID, nil)
+		return
+	}
+
+	c.Manager.startTyping(wsMessage.ChatID, c.UserID)
+}
+
+// typingThrottled 按连接限流 typing_start，间隔内的重复帧返回 true 直接丢弃，
+// 避免频繁的 typing_start 占满广播通道或查询数据库
+func (c *Client) typingThrottled(chatID string) bool {
+	now := time.Now()
+	if c.typingSentAt == nil {
+		c.typingSentAt = make(map[string]time.Time)
+	}
+	if now.Sub(c.typingSentAt[chatID]) < typingInterval {
+		return true
+	}
+	c.typingSentAt[chatID] = now
+	return false
+}
+
+// startTyping 记录输入状态并在状态开始时广播，已在输入中则只刷新过期时间
+func (m *Manager) startTyping(chatID, userID string) {
+	key := chatID + ":" + userID
+
+	m.typingMutex.Lock()
+	timer, active := m.typing[key]
+	if active {
+		timer.Reset(typingTimeout)
+	} else {
+		var expire *time.Timer
+		expire = time.AfterFunc(typingTimeout, func() {
+			m.stopTyping(chatID, userID, expire)
+		})
+		m.typing[key] = expire
+	}
+	m.typingMutex.Unlock()
+
+	if !active {
+		m.BroadcastEventExcept(chatID, userID, WSEventTypingStart, &TypingPayload{ChatID: chatID, UserID: userID})
+	}
+}
+
+// stopTyping 清除输入状态并广播 typing_stop。expired 非空时表示由过期计时器触发，
+// 只有计时器仍是当前计时器时才处理，避免清除重新开始的输入状态
+func (m *Manager) stopTyping(chatID, userID string, expired *time.Timer) {
+	key := chatID + ":" + userID
+
+	m.typingMutex.Lock()
+	timer, active := m.typing[key]
+	if !active || (expired != nil && timer != expired) {
+		m.typingMutex.Unlock()
+		return
+	}
+	timer.Stop()
+	delete(m.typing, key)
+	m.typingMutex.Unlock()
+
+	m.BroadcastEventExcept(chatID, userID, WSEventTypingStop, &TypingPayload{ChatID: chatID, UserID: userID})
+}
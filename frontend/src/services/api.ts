@@ -243,8 +243,17 @@ export const api = {
      * @returns Promise<Chat[]> - 聊天列表
      */
     getChats: async (): Promise<Chat[]> => {
-      const response = await apiClient.get<Chat[]>('/chats');
-      return response.data;
+      // 后端返回按最后活跃时间倒序的分页结果，逐页读取直到没有更多
+      const chats: Chat[] = [];
+      let cursor: string | undefined;
+      do {
+        const response = await apiClient.get<{ chats: Chat[]; hasMore: boolean; nextCursor?: string }>('/chats', {
+          params: cursor ? { cursor } : undefined,
+        });
+        chats.push(...response.data.chats);
+        cursor = response.data.hasMore ? response.data.nextCursor : undefined;
+      } while (cursor);
+      return chats;
     },
 
     /**